@@ -0,0 +1,924 @@
+package goutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrArchiveLimit is returned when an archive exceeds a size or file count limit.
+var ErrArchiveLimit = errors.New("archive exceeds limit")
+
+// ArchiveFormat is the format of an archive.
+type ArchiveFormat int
+
+const (
+	// ArchiveTarGz is a gzip-compressed tar archive.
+	ArchiveTarGz ArchiveFormat = iota + 1
+	// ArchiveTar is an uncompressed tar archive.
+	ArchiveTar
+	// ArchiveZip is a zip archive.
+	ArchiveZip
+)
+
+// String returns the usual file extension of the format, without the leading dot.
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveTarGz:
+		return "tar.gz"
+	case ArchiveTar:
+		return "tar"
+	case ArchiveZip:
+		return "zip"
+	default:
+		return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+	}
+}
+
+// DetectArchiveFormat returns the format matching the extension of name:
+// ".tar.gz" or ".tgz", ".tar" and ".zip".
+func DetectArchiveFormat(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	default:
+		return 0, fmt.Errorf("unknown archive format: %s", name)
+	}
+}
+
+// ArchiveProgress is reported after each entry is added or extracted.
+type ArchiveProgress struct {
+	// Path is the slash-separated path of the entry within the archive.
+	Path string
+	// Files is the number of entries processed so far.
+	Files int
+	// Bytes is the total size of the file contents processed so far.
+	Bytes int64
+}
+
+// ArchiveOption configures CreateArchive and ExtractArchive.
+type ArchiveOption func(*archiveOptions)
+
+// archiveOptions holds the settings collected from ArchiveOption values.
+type archiveOptions struct {
+	format      ArchiveFormat
+	include     globList
+	exclude     globList
+	maxSize     int64
+	maxFileSize int64
+	maxFiles    int
+	progress    func(ArchiveProgress)
+	// err records an invalid option, returned before any work is done.
+	err error
+}
+
+// WithArchiveFormat sets the format instead of detecting it from the file name or content.
+func WithArchiveFormat(format ArchiveFormat) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.format = format
+	}
+}
+
+// WithArchiveInclude only processes files whose path relative to the archive root, or base name,
+// matches one of the patterns (glob syntax, see GlobPattern). A malformed pattern is an error
+// wrapping path.ErrBadPattern.
+func WithArchiveInclude(patterns ...string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.include = appendGlobs(o.include, patterns, &o.err)
+	}
+}
+
+// WithArchiveExclude skips files and directories whose path relative to the archive root,
+// or base name, matches one of the patterns (glob syntax, see GlobPattern). A malformed pattern
+// is an error wrapping path.ErrBadPattern.
+func WithArchiveExclude(patterns ...string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.exclude = appendGlobs(o.exclude, patterns, &o.err)
+	}
+}
+
+// WithArchiveMaxSize limits the total size of the file contents, such as "10GiB" (see ConvertToBytes).
+// When extracting, it stops decompression bombs, whatever the sizes declared by the archive.
+func WithArchiveMaxSize(size string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.maxSize = o.parseSize(size)
+	}
+}
+
+// WithArchiveMaxFileSize limits the size of each file, such as "512MiB" (see ConvertToBytes).
+func WithArchiveMaxFileSize(size string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.maxFileSize = o.parseSize(size)
+	}
+}
+
+// WithArchiveMaxFiles limits the number of entries.
+func WithArchiveMaxFiles(n int) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.maxFiles = n
+	}
+}
+
+// WithArchiveProgress calls report after each entry is processed.
+func WithArchiveProgress(report func(ArchiveProgress)) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.progress = report
+	}
+}
+
+// parseSize converts a size limit, recording an error if it is invalid.
+func (o *archiveOptions) parseSize(size string) int64 {
+	n, err := ConvertToBytes(size)
+	if err == nil && n <= 0 {
+		err = errors.New("size must be positive")
+	}
+	if err != nil && o.err == nil {
+		o.err = fmt.Errorf("invalid size limit %q: %w", size, err)
+	}
+	return n
+}
+
+// skipped reports whether the entry is filtered out. Exclusions also apply to the parent
+// directories, and inclusions only to files.
+func (o *archiveOptions) skipped(rel string, isDir bool) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if o.exclude.match(p) {
+			return true
+		}
+	}
+	return !isDir && len(o.include) > 0 && !o.include.match(rel)
+}
+
+// newArchiveOptions applies the options.
+func newArchiveOptions(opts []ArchiveOption) (archiveOptions, error) {
+	var o archiveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o, o.err
+}
+
+// CreateArchive archives the content of the directory srcDir into the file archivePath,
+// whose format is detected from its extension unless WithArchiveFormat is given.
+// The archive is written atomically, with permissions 0600.
+//
+// Permissions, modification times and symbolic links are preserved.
+//
+// Example:
+//
+//	err := CreateArchive("backup.tar.gz", "/var/lib/app",
+//		WithArchiveExclude("*.tmp", "cache"),
+//		WithArchiveProgress(func(p ArchiveProgress) { log.Printf("%d files, %s", p.Files, ConvertBytes(uint64(p.Bytes))) }),
+//	)
+func CreateArchive(archivePath, srcDir string, opts ...ArchiveOption) error {
+	o, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+	if o.format == 0 {
+		if o.format, err = DetectArchiveFormat(archivePath); err != nil {
+			return err
+		}
+	}
+	w, err := NewAtomicWriter(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func(w *AtomicWriter) {
+		_ = w.Close()
+	}(w)
+
+	// Never add the archive, or its temporary file, to itself.
+	var own []fs.FileInfo
+	for _, p := range []string{w.file.Name(), archivePath} {
+		if info, err := os.Lstat(p); err == nil {
+			own = append(own, info)
+		}
+	}
+	skip := func(info fs.FileInfo) bool {
+		for _, ownInfo := range own {
+			if os.SameFile(info, ownInfo) {
+				return true
+			}
+		}
+		return false
+	}
+	if err := writeArchive(w, srcDir, o, skip); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// WriteArchive streams an archive of the content of srcDir to w. The format defaults to
+// ArchiveTarGz.
+func WriteArchive(w io.Writer, srcDir string, opts ...ArchiveOption) error {
+	o, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+	if o.format == 0 {
+		o.format = ArchiveTarGz
+	}
+	return writeArchive(w, srcDir, o, func(fs.FileInfo) bool { return false })
+}
+
+// archiveWriter adds entries to a tar or zip archive.
+type archiveWriter interface {
+	add(name string, info fs.FileInfo, link string, content io.Reader) error
+	close() error
+}
+
+// writeArchive walks srcDir and writes its entries.
+func writeArchive(w io.Writer, srcDir string, o archiveOptions, skip func(fs.FileInfo) bool) error {
+	info, err := os.Stat(srcDir)
+	if err != nil {
+		return fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source %s is not a directory", srcDir)
+	}
+
+	var aw archiveWriter
+	switch o.format {
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz}
+	case ArchiveTar:
+		aw = &tarArchiveWriter{tw: tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = &zipArchiveWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unsupported archive format: %v", o.format)
+	}
+
+	var progress ArchiveProgress
+	err = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if o.skipped(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if skip(info) {
+			return nil
+		}
+
+		progress.Files++
+		if o.maxFiles > 0 && progress.Files > o.maxFiles {
+			return fmt.Errorf("%w: more than %d files", ErrArchiveLimit, o.maxFiles)
+		}
+		switch {
+		case info.IsDir():
+			err = aw.add(rel+"/", info, "", nil)
+		case info.Mode()&fs.ModeSymlink != 0:
+			var target string
+			if target, err = os.Readlink(p); err == nil {
+				err = aw.add(rel, info, target, nil)
+			}
+		case info.Mode().IsRegular():
+			if err = o.checkSize(rel, info.Size(), &progress); err == nil {
+				err = addArchiveFile(aw, p, rel, info)
+			}
+		default:
+			// Devices, sockets and named pipes are not archived.
+			progress.Files--
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", p, err)
+		}
+		progress.Path = rel
+		if o.progress != nil {
+			o.progress(progress)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = aw.close()
+		return err
+	}
+	return aw.close()
+}
+
+// checkSize adds a file size to the progress, checking the limits.
+func (o *archiveOptions) checkSize(rel string, size int64, progress *ArchiveProgress) error {
+	if o.maxFileSize > 0 && size > o.maxFileSize {
+		return fmt.Errorf("%w: %s is larger than %s", ErrArchiveLimit, rel, ConvertBytes(uint64(o.maxFileSize)))
+	}
+	progress.Bytes += size
+	if o.maxSize > 0 && progress.Bytes > o.maxSize {
+		return fmt.Errorf("%w: content is larger than %s", ErrArchiveLimit, ConvertBytes(uint64(o.maxSize)))
+	}
+	return nil
+}
+
+// addArchiveFile adds the content of a regular file.
+func addArchiveFile(aw archiveWriter, p, rel string, info fs.FileInfo) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	// Do not write more than the size recorded in the header if the file grows meanwhile.
+	return aw.add(rel, info, "", io.LimitReader(f, info.Size()))
+}
+
+// tarArchiveWriter writes tar archives, optionally compressed with gzip.
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (a *tarArchiveWriter) add(name string, info fs.FileInfo, link string, content io.Reader) error {
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if content != nil {
+		_, err = io.Copy(a.tw, content)
+	}
+	return err
+}
+
+func (a *tarArchiveWriter) close() error {
+	err := a.tw.Close()
+	if a.gz != nil {
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+// zipArchiveWriter writes zip archives.
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) add(name string, info fs.FileInfo, link string, content io.Reader) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if !info.IsDir() {
+		hdr.Method = zip.Deflate
+	}
+	w, err := a.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	// Symbolic links are stored with their target as content.
+	if link != "" {
+		content = strings.NewReader(link)
+	}
+	if content != nil {
+		_, err = io.Copy(w, content)
+	}
+	return err
+}
+
+func (a *zipArchiveWriter) close() error {
+	return a.zw.Close()
+}
+
+// ExtractArchive extracts the archive file into dstDir, created if needed. The format is
+// detected from the extension, or from the content if the extension is unknown.
+//
+// Entries escaping dstDir, through their path or through symbolic links, are refused,
+// and nothing is ever written through a symbolic link: links are created last, then followed,
+// and all of them are removed if any resolves outside dstDir. Permissions, modification times
+// and symbolic links are preserved; ownership is not.
+//
+// Example:
+//
+//	err := ExtractArchive("upload.zip", "/srv/site",
+//		WithArchiveMaxSize("1GiB"),
+//		WithArchiveMaxFiles(10000),
+//	)
+func ExtractArchive(archivePath, dstDir string, opts ...ArchiveOption) error {
+	o, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+	if o.format == 0 {
+		o.format, _ = DetectArchiveFormat(archivePath)
+	}
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	if o.format == 0 {
+		if o.format, err = sniffArchiveFormat(bufio.NewReader(f)); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	if o.format == ArchiveZip {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return extractZip(f, info.Size(), dstDir, o)
+	}
+	return extractStream(f, dstDir, o)
+}
+
+// ReadArchive extracts an archive read from r into dstDir, with the same guarantees as
+// ExtractArchive. The format is detected from the content unless WithArchiveFormat is given.
+// Zip archives are spooled to a temporary file, since their index is at the end.
+func ReadArchive(r io.Reader, dstDir string, opts ...ArchiveOption) error {
+	o, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(r)
+	if o.format == 0 {
+		if o.format, err = sniffArchiveFormat(br); err != nil {
+			return err
+		}
+	}
+	if o.format != ArchiveZip {
+		return extractStream(br, dstDir, o)
+	}
+
+	tmp, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func(tmp *os.File) {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}(tmp)
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	return extractZip(tmp, size, dstDir, o)
+}
+
+// sniffArchiveFormat detects the format from the first bytes of the archive.
+func sniffArchiveFormat(br *bufio.Reader) (ArchiveFormat, error) {
+	header, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ArchiveTar, nil
+	default:
+		return 0, errors.New("unknown archive format")
+	}
+}
+
+// extractStream extracts a tar or tar.gz archive.
+func extractStream(r io.Reader, dstDir string, o archiveOptions) error {
+	if o.format == ArchiveTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer func(gz *gzip.Reader) {
+			_ = gz.Close()
+		}(gz)
+		r = gz
+	} else if o.format != ArchiveTar {
+		return fmt.Errorf("unsupported archive format: %v", o.format)
+	}
+
+	e, err := newExtractor(dstDir, o)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		var kind entryKind
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			kind = entryDir
+		case tar.TypeReg:
+			kind = entryFile
+		case tar.TypeSymlink:
+			kind = entrySymlink
+		case tar.TypeLink:
+			kind = entryHardLink
+		default:
+			// Devices, named pipes and extended headers are not extracted.
+			continue
+		}
+		entry := archiveEntry{
+			name: hdr.Name, kind: kind, mode: hdr.FileInfo().Mode(), modTime: hdr.ModTime,
+			size: hdr.Size, link: hdr.Linkname, content: tr,
+		}
+		if err := e.extract(entry); err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+// extractZip extracts a zip archive.
+func extractZip(r io.ReaderAt, size int64, dstDir string, o archiveOptions) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	e, err := newExtractor(dstDir, o)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		entry := archiveEntry{name: f.Name, mode: mode, modTime: f.Modified, size: int64(f.UncompressedSize64)}
+		switch {
+		case mode.IsDir():
+			entry.kind = entryDir
+		case mode&fs.ModeSymlink != 0:
+			entry.kind = entrySymlink
+		case mode.IsRegular():
+			entry.kind = entryFile
+		default:
+			continue
+		}
+		err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", f.Name, err)
+			}
+			defer func(rc io.ReadCloser) {
+				_ = rc.Close()
+			}(rc)
+			if entry.kind == entrySymlink {
+				target, err := io.ReadAll(io.LimitReader(rc, 4096))
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", f.Name, err)
+				}
+				entry.link = string(target)
+			}
+			entry.content = rc
+			return e.extract(entry)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+// entryKind is the type of an archive entry.
+type entryKind int
+
+const (
+	entryFile entryKind = iota
+	entryDir
+	entrySymlink
+	entryHardLink
+)
+
+// archiveEntry is an entry read from a tar or zip archive.
+type archiveEntry struct {
+	name    string
+	kind    entryKind
+	mode    fs.FileMode
+	modTime time.Time
+	size    int64
+	link    string
+	content io.Reader
+}
+
+// extractor writes archive entries below a root directory.
+type extractor struct {
+	o        archiveOptions
+	root     string
+	progress ArchiveProgress
+	// dirs are applied their metadata last, since extracting files updates their times.
+	dirs []archiveEntry
+	// links are created last, so no file is ever written through them.
+	links []archiveEntry
+}
+
+// newExtractor creates the destination directory.
+func newExtractor(dstDir string, o archiveOptions) (*extractor, error) {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	root, err := filepath.Abs(dstDir)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	return &extractor{o: o, root: root}, nil
+}
+
+// extract validates and writes an entry.
+func (e *extractor) extract(entry archiveEntry) error {
+	name := strings.TrimSuffix(entry.name, "/")
+	rel := filepath.FromSlash(name)
+	if name == "" || name == "." || !filepath.IsLocal(rel) {
+		if name == "." || name == "./" {
+			return nil
+		}
+		return fmt.Errorf("illegal path in archive: %q", entry.name)
+	}
+	slashRel := filepath.ToSlash(filepath.Clean(rel))
+	if e.o.skipped(slashRel, entry.kind == entryDir) {
+		return nil
+	}
+	e.progress.Files++
+	if e.o.maxFiles > 0 && e.progress.Files > e.o.maxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrArchiveLimit, e.o.maxFiles)
+	}
+
+	target := filepath.Join(e.root, rel)
+	if err := e.checkParents(target); err != nil {
+		return err
+	}
+	var err error
+	switch entry.kind {
+	case entryDir:
+		err = e.mkdir(target)
+		entry.name = target
+		e.dirs = append(e.dirs, entry)
+	case entryFile:
+		err = e.writeFile(target, slashRel, entry)
+	case entrySymlink:
+		if err = e.checkLink(rel, entry.link); err == nil {
+			entry.name = target
+			e.links = append(e.links, entry)
+		}
+	case entryHardLink:
+		err = e.hardLink(target, entry.link)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", entry.name, err)
+	}
+	e.progress.Path = slashRel
+	if e.o.progress != nil {
+		e.o.progress(e.progress)
+	}
+	return nil
+}
+
+// checkParents creates the parent directories of target, refusing symbolic links among them.
+func (e *extractor) checkParents(target string) error {
+	rel, err := filepath.Rel(e.root, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	p := e.root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			if err := os.Mkdir(p, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("refusing to extract through %s, which is not a directory", p)
+		}
+	}
+	return nil
+}
+
+// mkdir creates the directory target, refusing an existing symbolic link.
+func (e *extractor) mkdir(target string) error {
+	info, err := os.Lstat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.Mkdir(target, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s exists and is not a directory", target)
+	}
+	return nil
+}
+
+// checkLink refuses symbolic links whose target is absolute or escapes the root.
+func (e *extractor) checkLink(rel, link string) error {
+	if link == "" || filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+		return fmt.Errorf("illegal symbolic link target: %q", link)
+	}
+	if !filepath.IsLocal(filepath.Join(filepath.Dir(rel), filepath.FromSlash(link))) {
+		return fmt.Errorf("symbolic link target escapes the destination: %q", link)
+	}
+	return nil
+}
+
+// maxArchiveLinkHops bounds the symbolic links followed by checkResolved, against loops.
+const maxArchiveLinkHops = 255
+
+// checkResolved follows the symbolic links of the path name below the root, refusing it if
+// it resolves outside. Missing components, such as the target of a dangling link, are
+// resolved lexically, so a link cannot point outside even before its target exists.
+func (e *extractor) checkResolved(name string) error {
+	rel, err := filepath.Rel(e.root, name)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	resolved := ""
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return errors.New("symbolic link escapes the destination")
+			}
+			if resolved = filepath.Dir(resolved); resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(e.root, next))
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if hops++; hops > maxArchiveLinkHops {
+			return errors.New("too many levels of symbolic links")
+		}
+		target, err := os.Readlink(filepath.Join(e.root, next))
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) {
+			// Only links already in the destination may be absolute.
+			if target, err = filepath.Rel(e.root, target); err != nil || !filepath.IsLocal(target) && target != "." {
+				return errors.New("symbolic link escapes the destination")
+			}
+			resolved = ""
+		}
+		parts = append(strings.Split(target, string(filepath.Separator)), parts...)
+	}
+	return nil
+}
+
+// removeExisting removes target if it is a file or a symbolic link, so it is replaced
+// rather than written through.
+func removeExisting(target string) error {
+	info, err := os.Lstat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is an existing directory", target)
+	}
+	return os.Remove(target)
+}
+
+// writeFile writes a regular file, enforcing the size limits on the actual content.
+func (e *extractor) writeFile(target, rel string, entry archiveEntry) (err error) {
+	if err := e.o.checkSize(rel, entry.size, &e.progress); err != nil {
+		return err
+	}
+	if err := removeExisting(target); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, entry.mode.Perm()|0200)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}(f)
+
+	// Declared sizes cannot be trusted: copy at most one byte more than declared.
+	n, err := io.Copy(f, io.LimitReader(entry.content, entry.size+1))
+	if err != nil {
+		return err
+	}
+	if n > entry.size {
+		return fmt.Errorf("%w: %s is larger than its declared size", ErrArchiveLimit, rel)
+	}
+	if err := f.Chmod(entry.mode.Perm()); err != nil {
+		return err
+	}
+	if !entry.modTime.IsZero() {
+		return os.Chtimes(target, time.Time{}, entry.modTime)
+	}
+	return nil
+}
+
+// hardLink links target to a file previously extracted.
+func (e *extractor) hardLink(target, link string) error {
+	linkRel := filepath.FromSlash(strings.TrimSuffix(link, "/"))
+	if !filepath.IsLocal(linkRel) {
+		return fmt.Errorf("illegal hard link target: %q", link)
+	}
+	source := filepath.Join(e.root, linkRel)
+	if err := e.checkParents(source); err != nil {
+		return err
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("hard link target is not a regular file: %q", link)
+	}
+	if err := removeExisting(target); err != nil {
+		return err
+	}
+	return os.Link(source, target)
+}
+
+// finish creates the symbolic links and applies the directory metadata.
+func (e *extractor) finish() error {
+	for _, link := range e.links {
+		if err := removeExisting(link.name); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", link.name, err)
+		}
+		if err := os.Symlink(link.link, link.name); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", link.name, err)
+		}
+	}
+	// Each link was checked lexically, but links may chain through links created after them:
+	// once all exist, follow each one, and remove them all if any escapes the root.
+	for _, link := range e.links {
+		if err := e.checkResolved(link.name); err != nil {
+			for _, created := range e.links {
+				_ = os.Remove(created.name)
+			}
+			return fmt.Errorf("failed to extract %s: %w", link.name, err)
+		}
+	}
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		dir := e.dirs[i]
+		if err := os.Chmod(dir.name, dir.mode.Perm()|0700); err != nil {
+			return err
+		}
+		if !dir.modTime.IsZero() {
+			_ = os.Chtimes(dir.name, time.Time{}, dir.modTime)
+		}
+	}
+	return nil
+}
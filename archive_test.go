@@ -0,0 +1,193 @@
+package goutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	createTestTree(t, src, map[string]string{
+		"a.txt":         "a",
+		"sub/b.txt":     "b",
+		"sub/skip.tmp":  "tmp",
+		"cache/c.txt":   "c",
+		"bin/script.sh": "#!/bin/sh",
+	})
+	if err := os.Chmod(filepath.Join(src, "bin", "script.sh"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/b.txt", filepath.Join(src, "link")); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+
+	for _, name := range []string{"backup.tar.gz", "backup.tar", "backup.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), name)
+			var created []string
+			err := CreateArchive(archivePath, src,
+				WithArchiveExclude("*.tmp", "cache"),
+				WithArchiveProgress(func(p ArchiveProgress) { created = append(created, p.Path) }),
+			)
+			if err != nil {
+				t.Fatalf("Error creating archive: %v", err)
+			}
+			if len(created) != 6 {
+				t.Errorf("Expected 6 entries, got %v", created)
+			}
+
+			dst := filepath.Join(t.TempDir(), "restore")
+			if err := ExtractArchive(archivePath, dst); err != nil {
+				t.Fatalf("Error extracting archive: %v", err)
+			}
+			expected := []string{"a.txt", "bin/script.sh", "link", "sub/b.txt"}
+			if files := listTestTree(t, dst); !equalStrings(files, expected) {
+				t.Errorf("Expected %v, got %v", expected, files)
+			}
+			if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "sub/b.txt" {
+				t.Errorf("Expected the symbolic link to be preserved, got %q (%v)", target, err)
+			}
+			if info, err := os.Stat(filepath.Join(dst, "bin", "script.sh")); err != nil || info.Mode().Perm() != 0750 {
+				t.Errorf("Expected mode 0750, got %v (%v)", info.Mode(), err)
+			}
+
+			var txt []string
+			err = ExtractArchive(archivePath, filepath.Join(t.TempDir(), "txt"),
+				WithArchiveInclude("*.txt"),
+				WithArchiveProgress(func(p ArchiveProgress) { txt = append(txt, p.Path) }),
+			)
+			if err != nil {
+				t.Fatalf("Error extracting archive: %v", err)
+			}
+			if !equalStrings(txt, []string{"a.txt", "bin", "sub", "sub/b.txt"}) {
+				t.Errorf("Unexpected filtered entries: %v", txt)
+			}
+		})
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	src := t.TempDir()
+	createTestTree(t, src, map[string]string{"a.txt": "a"})
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, src, WithArchiveFormat(ArchiveZip)); err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+	dst := t.TempDir()
+	if err := ReadArchive(&buf, dst); err != nil {
+		t.Fatalf("Error reading archive: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "a.txt")); err != nil || string(data) != "a" {
+		t.Errorf("Unexpected content %q (%v)", data, err)
+	}
+}
+
+// testTarEntry is an entry of a handcrafted tar archive.
+type testTarEntry struct {
+	name, link, content string
+	typeflag            byte
+}
+
+// buildTestTar builds a tar archive containing the entries.
+func buildTestTar(t *testing.T, entries ...testTarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typeflag, Mode: 0644, Size: int64(len(e.content))}
+		if e.typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractArchiveRejectsEscapes(t *testing.T) {
+	tests := map[string][]testTarEntry{
+		"parent path":   {{name: "../evil.txt", content: "evil"}},
+		"absolute path": {{name: "/tmp/evil.txt", content: "evil"}},
+		"symlink":       {{name: "link", link: "../outside", typeflag: tar.TypeSymlink}},
+		"symlink chain": {
+			{name: "d", link: ".", typeflag: tar.TypeSymlink},
+			{name: "x", link: "d/..", typeflag: tar.TypeSymlink},
+		},
+		"symlink through a later link": {
+			{name: "l1", link: "sub/l2/..", typeflag: tar.TypeSymlink},
+			{name: "sub/l2", link: "..", typeflag: tar.TypeSymlink},
+		},
+		"dangling symlink through a later link": {
+			{name: "l1", link: "sub/l2/../missing", typeflag: tar.TypeSymlink},
+			{name: "sub/l2", link: "..", typeflag: tar.TypeSymlink},
+		},
+		"write through symlink": {
+			{name: "link", link: "sub", typeflag: tar.TypeSymlink},
+			{name: "link/evil.txt", content: "evil"},
+		},
+		"hard link": {{name: "link", link: "../../etc/passwd", typeflag: tar.TypeLink}},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			base := t.TempDir()
+			dst := filepath.Join(base, "dst")
+			if err := ReadArchive(buildTestTar(t, entries...), dst); err == nil {
+				t.Errorf("Expected an error")
+			}
+			if files := listTestTree(t, base); len(files) > 0 && !strings.HasPrefix(files[0], "dst/") {
+				t.Errorf("Expected nothing outside the destination, got %v", files)
+			}
+			_ = filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type()&fs.ModeSymlink != 0 {
+					t.Errorf("Expected no symbolic link to be left, got %s", path)
+				}
+				return nil
+			})
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("../../evil.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_ = zw.Close()
+	if err := ReadArchive(&buf, t.TempDir()); err == nil || !strings.Contains(err.Error(), "illegal path") {
+		t.Errorf("Expected zip slip to be refused, got %v", err)
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	large := strings.Repeat("x", 2000)
+	if err := ReadArchive(buildTestTar(t, testTarEntry{name: "large", content: large}), t.TempDir(), WithArchiveMaxSize("1KB")); !errors.Is(err, ErrArchiveLimit) {
+		t.Errorf("Expected ErrArchiveLimit for the total size, got %v", err)
+	}
+	if err := ReadArchive(buildTestTar(t, testTarEntry{name: "large", content: large}), t.TempDir(), WithArchiveMaxFileSize("1Ki")); !errors.Is(err, ErrArchiveLimit) {
+		t.Errorf("Expected ErrArchiveLimit for the file size, got %v", err)
+	}
+	entries := []testTarEntry{{name: "a"}, {name: "b"}, {name: "c"}}
+	if err := ReadArchive(buildTestTar(t, entries...), t.TempDir(), WithArchiveMaxFiles(2)); !errors.Is(err, ErrArchiveLimit) {
+		t.Errorf("Expected ErrArchiveLimit for the file count, got %v", err)
+	}
+	if err := ReadArchive(buildTestTar(t, entries...), t.TempDir(), WithArchiveMaxSize("lots")); err == nil {
+		t.Errorf("Expected an error for an invalid size")
+	}
+	if err := ReadArchive(buildTestTar(t, entries...), t.TempDir(), WithArchiveExclude("{secrets")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Expected path.ErrBadPattern for a malformed pattern, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package goutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// defaultFileMode is the mode of files created by WriteToFile and the atomic writers.
+const defaultFileMode fs.FileMode = 0600
+
+// WriteOption configures the atomic file writers.
+type WriteOption func(*writeOptions)
+
+// writeOptions holds the settings collected from WriteOption values.
+type writeOptions struct {
+	mode     fs.FileMode
+	modeSet  bool
+	uid, gid int
+	ownerSet bool
+	// preserveOwner is set when the owner is copied from an existing target,
+	// in which case a failure to change it is not an error.
+	preserveOwner bool
+	// noReplace makes Commit fail if the target already exists.
+	noReplace bool
+	// createMode is the mode of a new target before the umask, as with os.OpenFile.
+	createMode    fs.FileMode
+	createModeSet bool
+}
+
+// WithFileMode sets the permissions of the written file. By default, the permissions of an
+// existing file are preserved, and new files are created with 0600.
+func WithFileMode(mode fs.FileMode) WriteOption {
+	return func(o *writeOptions) {
+		o.mode = mode
+		o.modeSet = true
+	}
+}
+
+// WithFileOwner sets the owner of the written file. By default, the owner of an existing file
+// is preserved when permitted. It is ignored on platforms without Unix ownership.
+func WithFileOwner(uid, gid int) WriteOption {
+	return func(o *writeOptions) {
+		o.uid, o.gid = uid, gid
+		o.ownerSet = true
+	}
+}
+
+// withNoReplace makes Commit fail with an error wrapping fs.ErrExist if the target exists.
+func withNoReplace() WriteOption {
+	return func(o *writeOptions) {
+		o.noReplace = true
+	}
+}
+
+// withCreateMode creates a new target with mode minus the umask, like os.OpenFile,
+// instead of 0600. It does not override WithFileMode or the mode of an existing target.
+func withCreateMode(mode fs.FileMode) WriteOption {
+	return func(o *writeOptions) {
+		o.createMode = mode
+		o.createModeSet = true
+	}
+}
+
+// AtomicWriter writes to a temporary file that atomically replaces the target on Commit.
+//
+// Readers of the target never observe a partially written file, and a crash leaves
+// either the old or the new content in place.
+type AtomicWriter struct {
+	file *os.File
+	path string
+	opts writeOptions
+	done bool
+}
+
+// NewAtomicWriter creates a temporary file in the directory of filePath.
+// Call Commit to replace the target, and Close to discard the temporary file
+// if Commit was not called; deferring Close is always safe.
+//
+// Example:
+//
+//	w, err := NewAtomicWriter("state.json")
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	if err := json.NewEncoder(w).Encode(state); err != nil {
+//		return err
+//	}
+//	return w.Commit()
+func NewAtomicWriter(filePath string, opts ...WriteOption) (*AtomicWriter, error) {
+	o := writeOptions{mode: defaultFileMode}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// Preserve the permissions and ownership of an existing target.
+	info, statErr := os.Stat(filePath)
+	if statErr == nil {
+		if !o.modeSet {
+			o.mode = info.Mode().Perm()
+		}
+		if !o.ownerSet {
+			o.uid, o.gid, o.preserveOwner = fileOwner(info)
+		}
+	}
+
+	dir, base := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	var file *os.File
+	var err error
+	if statErr != nil && o.createModeSet && !o.modeSet {
+		file, err = createTempWithMode(dir, "."+base+".tmp-", o.createMode)
+		if err == nil {
+			// The temporary file was created with the umask applied, so its mode is the
+			// one os.OpenFile would have given the target.
+			var tmpInfo fs.FileInfo
+			if tmpInfo, err = file.Stat(); err == nil {
+				o.mode = tmpInfo.Mode().Perm()
+			} else {
+				_ = file.Close()
+				_ = os.Remove(file.Name())
+			}
+		}
+	} else {
+		file, err = os.CreateTemp(dir, "."+base+".tmp-*")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file for %s: %w", filePath, err)
+	}
+	return &AtomicWriter{file: file, path: filePath, opts: o}, nil
+}
+
+// createTempWithMode creates a new file named prefix followed by a random suffix in dir,
+// with mode minus the umask.
+func createTempWithMode(dir, prefix string, mode fs.FileMode) (*os.File, error) {
+	for range 100 {
+		name := filepath.Join(dir, prefix+rand.Text())
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+		if !errors.Is(err, fs.ErrExist) {
+			return file, err
+		}
+	}
+	return nil, fmt.Errorf("failed to find an unused temporary file name in %s", dir)
+}
+
+// Write writes p to the temporary file.
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// ReadFrom copies r into the temporary file.
+func (w *AtomicWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(w.file, r)
+}
+
+// Commit applies the permissions and ownership, flushes the temporary file to disk,
+// renames it over the target and flushes the parent directory.
+func (w *AtomicWriter) Commit() error {
+	if w.done {
+		return errors.New("atomic writer is already committed or closed")
+	}
+	w.done = true
+	if err := w.finish(); err != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+		return fmt.Errorf("failed to write to file %s: %w", w.path, err)
+	}
+	return nil
+}
+
+// finish performs the steps of Commit.
+func (w *AtomicWriter) finish() error {
+	if err := w.file.Chmod(w.opts.mode); err != nil {
+		return err
+	}
+	if w.opts.ownerSet {
+		if err := chownFile(w.file, w.opts.uid, w.opts.gid); err != nil {
+			return err
+		}
+	} else if w.opts.preserveOwner {
+		_ = chownFile(w.file, w.opts.uid, w.opts.gid)
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.opts.noReplace {
+		// A hard link fails if the target exists, unlike a rename.
+		if err := os.Link(w.file.Name(), w.path); err != nil {
+			return err
+		}
+		if err := os.Remove(w.file.Name()); err != nil {
+			return err
+		}
+	} else if err := os.Rename(w.file.Name(), w.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// Close discards the temporary file if Commit was not called. It is a no-op after Commit.
+func (w *AtomicWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	_ = w.file.Close()
+	if err := os.Remove(w.file.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// WriteFileAtomic atomically replaces the file at filePath with data.
+func WriteFileAtomic(filePath string, data []byte, opts ...WriteOption) error {
+	return WriteReaderAtomic(filePath, bytes.NewReader(data), opts...)
+}
+
+// WriteReaderAtomic atomically replaces the file at filePath with the content of r.
+func WriteReaderAtomic(filePath string, r io.Reader, opts ...WriteOption) error {
+	w, err := NewAtomicWriter(filePath, opts...)
+	if err != nil {
+		return err
+	}
+	defer func(w *AtomicWriter) {
+		_ = w.Close()
+	}(w)
+
+	if _, err := io.Copy(w.file, r); err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", filePath, err)
+	}
+	return w.Commit()
+}
+
+// WriteToFileAtomic is the atomic and durable variant of WriteToFile.
+// The content is written to a temporary file in the same directory, flushed to disk,
+// and renamed over the target, so a crash never leaves a truncated file.
+func WriteToFileAtomic(filePath, content string, opts ...WriteOption) error {
+	return WriteFileAtomic(filePath, []byte(content), opts...)
+}
+
+// syncDir flushes the directory entry changes (such as a rename) to disk.
+func syncDir(dir string) error {
+	if !dirSyncSupported {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func(d *os.File) {
+		_ = d.Close()
+	}(d)
+	return d.Sync()
+}
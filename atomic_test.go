@@ -0,0 +1,82 @@
+package goutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteToFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.json")
+
+	if err := WriteToFileAtomic(filePath, `{"version": 1}`); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Error reading file info: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Permissions of an existing file are preserved.
+	if err := os.Chmod(filePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(filePath, []byte(`{"version": 2}`)); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	if info, _ := os.Stat(filePath); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %v", info.Mode().Perm())
+	}
+
+	if err := WriteReaderAtomic(filePath, strings.NewReader(`{"version": 3}`), WithFileMode(0644)); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	data, _ := os.ReadFile(filePath)
+	if string(data) != `{"version": 3}` {
+		t.Errorf("Unexpected content: %s", data)
+	}
+	if info, _ := os.Stat(filePath); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicWriterAbort(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "state")
+	if err := WriteToFile(filePath, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewAtomicWriter(filePath)
+	if err != nil {
+		t.Fatalf("Error creating writer: %v", err)
+	}
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error closing writer: %v", err)
+	}
+	if err := w.Commit(); err == nil {
+		t.Errorf("Expected an error when committing a closed writer")
+	}
+
+	data, _ := os.ReadFile(filePath)
+	if string(data) != "old" {
+		t.Errorf("Expected the original content, got %s", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be removed, got %d entries", len(entries))
+	}
+}
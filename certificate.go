@@ -0,0 +1,204 @@
+package goutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// CertificateInfo describes an X.509 certificate in a human-readable form.
+type CertificateInfo struct {
+	Source         string    // File the certificate was read from, if any
+	Subject        string    // Distinguished name of the subject
+	Issuer         string    // Distinguished name of the issuer
+	CommonName     string    // Subject common name
+	DNSNames       []string  // DNS subject alternative names
+	IPAddresses    []string  // IP subject alternative names
+	EmailAddresses []string  // Email subject alternative names
+	URIs           []string  // URI subject alternative names
+	SerialNumber   string    // Serial number in hexadecimal
+	KeyType        string    // Public key algorithm and size, e.g. "RSA-2048" or "ECDSA-P-256"
+	NotBefore      time.Time // Start of the validity period
+	NotAfter       time.Time // End of the validity period
+	IsCA           bool      // Whether the certificate is a certificate authority
+	Fingerprint    string    // SHA-256 fingerprint, colon-separated
+}
+
+// ExpiresWithin reports whether the certificate is expired or expires within d.
+func (c CertificateInfo) ExpiresWithin(d time.Duration) bool {
+	return time.Until(c.NotAfter) <= d
+}
+
+// ParseCertificates parses all certificates from PEM data.
+// Non-certificate blocks are skipped; an error is returned if no certificate is found.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PEM data")
+	}
+	return certs, nil
+}
+
+// InspectCertificate returns the information of a parsed certificate.
+func InspectCertificate(cert *x509.Certificate) CertificateInfo {
+	info := CertificateInfo{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.Text(16),
+		KeyType:        publicKeyType(cert.PublicKey),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		IsCA:           cert.IsCA,
+		Fingerprint:    CertificateFingerprint(cert),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
+
+// InspectCertificates parses a PEM bundle and returns the information of each certificate.
+func InspectCertificates(data []byte) ([]CertificateInfo, error) {
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]CertificateInfo, 0, len(certs))
+	for _, cert := range certs {
+		infos = append(infos, InspectCertificate(cert))
+	}
+	return infos, nil
+}
+
+// InspectCertificateFile reads a PEM bundle from a file and returns the information of each certificate.
+func InspectCertificateFile(filePath string) ([]CertificateInfo, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file %s: %w", filePath, err)
+	}
+	infos, err := InspectCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	for i := range infos {
+		infos[i].Source = filePath
+	}
+	return infos, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of the certificate
+// as colon-separated uppercase hex (e.g., "AB:CD:...").
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	encoded := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(encoded); i += 2 {
+		parts = append(parts, encoded[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// VerifyKeyPair checks that the private key matches the public key of the first certificate.
+func VerifyKeyPair(certPEM, keyPEM []byte) error {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("certificate and key do not match: %w", err)
+	}
+	return nil
+}
+
+// VerifyChain checks that a PEM bundle is ordered from leaf to root, with each certificate
+// signed by the next one, and that the leaf verifies against roots.
+// If roots is nil, the system certificate pool is used.
+func VerifyChain(bundle []byte, roots *x509.CertPool) error {
+	certs, err := ParseCertificates(bundle)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(certs)-1; i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return fmt.Errorf("certificate %d (%s) is not signed by certificate %d (%s): %w",
+				i, certs[i].Subject, i+1, certs[i+1].Subject, err)
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify certificate chain: %w", err)
+	}
+	return nil
+}
+
+// ExpiringCertificates reads the certificate files and returns the certificates that are
+// expired or expire within threshold, a duration string such as "720h".
+func ExpiringCertificates(threshold string, files ...string) ([]CertificateInfo, error) {
+	d, err := ParseDuration(threshold)
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q: %w", threshold, err)
+	}
+	var expiring []CertificateInfo
+	for _, file := range files {
+		infos, err := InspectCertificateFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.ExpiresWithin(d) {
+				expiring = append(expiring, info)
+			}
+		}
+	}
+	return expiring, nil
+}
+
+// publicKeyType describes the public key algorithm and size.
+func publicKeyType(key any) string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
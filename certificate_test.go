@@ -0,0 +1,90 @@
+package goutils
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestInspectCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, "api", "api.example.com", "www.example.com")
+
+	infos, err := InspectCertificates(append(certPEM, ca.certPEM...))
+	if err != nil {
+		t.Fatalf("Error inspecting certificates: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 certificates, got %d", len(infos))
+	}
+	leaf := infos[0]
+	if leaf.CommonName != "api" || len(leaf.DNSNames) != 2 || leaf.KeyType != "ECDSA-P-256" {
+		t.Errorf("Unexpected certificate info: %+v", leaf)
+	}
+	if leaf.Issuer != "CN=Test CA" || !infos[1].IsCA {
+		t.Errorf("Unexpected issuer info: %+v", infos[1])
+	}
+	if len(leaf.Fingerprint) != 95 {
+		t.Errorf("Unexpected fingerprint: %s", leaf.Fingerprint)
+	}
+
+	if _, err := InspectCertificates([]byte("invalid")); err == nil {
+		t.Errorf("Expected an error for invalid PEM data")
+	}
+}
+
+func TestVerifyKeyPairAndChain(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "api", "api.example.com")
+	_, otherKeyPEM := ca.issue(t, "other", "other.example.com")
+
+	if err := VerifyKeyPair(certPEM, keyPEM); err != nil {
+		t.Errorf("Expected matching key pair: %v", err)
+	}
+	if err := VerifyKeyPair(certPEM, otherKeyPEM); err == nil {
+		t.Errorf("Expected an error for a mismatched key")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if err := VerifyChain(append(certPEM, ca.certPEM...), roots); err != nil {
+		t.Errorf("Expected a valid chain: %v", err)
+	}
+	if err := VerifyChain(append(ca.certPEM, certPEM...), roots); err == nil {
+		t.Errorf("Expected an error for a misordered chain")
+	}
+	if err := VerifyChain(certPEM, newTestCAPool(t)); err == nil {
+		t.Errorf("Expected an error for an unknown CA")
+	}
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, "api", "api.example.com")
+	file := writeTestFile(t, dir, "api.crt", certPEM)
+
+	expiring, err := ExpiringCertificates("48h", file)
+	if err != nil {
+		t.Fatalf("Error checking expiry: %v", err)
+	}
+	if len(expiring) != 1 || expiring[0].Source != file {
+		t.Errorf("Expected the certificate to expire within 48h, got %+v", expiring)
+	}
+	expiring, err = ExpiringCertificates("1h", file)
+	if err != nil {
+		t.Fatalf("Error checking expiry: %v", err)
+	}
+	if len(expiring) != 0 {
+		t.Errorf("Expected no certificate to expire within 1h, got %+v", expiring)
+	}
+	if _, err := ExpiringCertificates("soon", file); err == nil {
+		t.Errorf("Expected an error for an invalid threshold")
+	}
+}
+
+// newTestCAPool returns a pool containing an unrelated CA.
+func newTestCAPool(t *testing.T) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(newTestCA(t).cert)
+	return pool
+}
@@ -0,0 +1,389 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HashAlgorithm identifies a checksum algorithm.
+type HashAlgorithm string
+
+// Supported hash algorithms.
+const (
+	SHA256 HashAlgorithm = "sha256"
+	SHA512 HashAlgorithm = "sha512"
+	SHA1   HashAlgorithm = "sha1"
+	MD5    HashAlgorithm = "md5"
+	// CRC32 is the IEEE CRC-32, encoded big-endian.
+	CRC32 HashAlgorithm = "crc32"
+)
+
+// algorithmsBySize maps a digest length to its algorithm, used to detect the algorithm of a manifest.
+var algorithmsBySize = map[int]HashAlgorithm{
+	sha256.Size: SHA256,
+	sha512.Size: SHA512,
+	sha1.Size:   SHA1,
+	md5.Size:    MD5,
+	crc32.Size:  CRC32,
+}
+
+// ParseHashAlgorithm parses an algorithm name such as "sha256", "SHA-256" or "md5".
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	algorithm := HashAlgorithm(strings.ToLower(strings.ReplaceAll(name, "-", "")))
+	if _, err := NewHash(algorithm); err != nil {
+		return "", err
+	}
+	return algorithm, nil
+}
+
+// NewHash returns a new hash.Hash computing the algorithm.
+func NewHash(algorithm HashAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case MD5:
+		return md5.New(), nil
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %q", algorithm)
+	}
+}
+
+// Checksum is a digest computed by ChecksumReader or ChecksumFile.
+type Checksum []byte
+
+// Hex returns the checksum as lowercase hexadecimal.
+func (c Checksum) Hex() string {
+	return hex.EncodeToString(c)
+}
+
+// Base64 returns the checksum in standard base64 encoding.
+func (c Checksum) Base64() string {
+	return base64.StdEncoding.EncodeToString(c)
+}
+
+// String returns the checksum as lowercase hexadecimal.
+func (c Checksum) String() string {
+	return c.Hex()
+}
+
+// Equal reports whether both checksums are identical.
+func (c Checksum) Equal(other Checksum) bool {
+	return bytes.Equal(c, other)
+}
+
+// ChecksumReader computes the checksum of everything read from r.
+func ChecksumReader(r io.Reader, algorithm HashAlgorithm) (Checksum, error) {
+	h, err := NewHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// ChecksumFile computes the checksum of the file content, streaming it from disk.
+//
+// Example:
+//
+//	sum, err := ChecksumFile("backup.tar.gz", SHA256)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(sum.Hex())
+func ChecksumFile(filePath string, algorithm HashAlgorithm) (Checksum, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	sum, err := ChecksumReader(f, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute checksum of %s: %w", filePath, err)
+	}
+	return sum, nil
+}
+
+// VerifyFileChecksum reports whether the file matches the expected checksum,
+// given in hexadecimal or base64.
+func VerifyFileChecksum(filePath string, algorithm HashAlgorithm, expected string) (bool, error) {
+	want, err := decodeChecksum(expected)
+	if err != nil {
+		return false, err
+	}
+	sum, err := ChecksumFile(filePath, algorithm)
+	if err != nil {
+		return false, err
+	}
+	return sum.Equal(want), nil
+}
+
+// decodeChecksum decodes a hexadecimal or base64 checksum.
+func decodeChecksum(s string) (Checksum, error) {
+	s = strings.TrimSpace(s)
+	if sum, err := hex.DecodeString(s); err == nil {
+		return sum, nil
+	}
+	if sum, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return sum, nil
+	}
+	return nil, fmt.Errorf("invalid checksum: %q", s)
+}
+
+// ManifestEntry is a line of a checksum manifest.
+type ManifestEntry struct {
+	// Path is the slash-separated path of the file, relative to the manifest directory.
+	Path      string
+	Checksum  Checksum
+	Algorithm HashAlgorithm
+}
+
+// CreateManifest computes the checksum of every regular file under dir,
+// with paths relative to dir, sorted by path.
+func CreateManifest(dir string, algorithm HashAlgorithm) ([]ManifestEntry, error) {
+	if _, err := NewHash(algorithm); err != nil {
+		return nil, err
+	}
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ManifestEntry, 0, len(files))
+	for _, rel := range files {
+		sum, err := ChecksumFile(filepath.Join(dir, filepath.FromSlash(rel)), algorithm)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ManifestEntry{Path: rel, Checksum: sum, Algorithm: algorithm})
+	}
+	return entries, nil
+}
+
+// WriteManifest writes the entries in the format of sha256sum and related tools:
+// the hexadecimal checksum, two spaces and the path.
+func WriteManifest(w io.Writer, entries []ManifestEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		name := entry.Path
+		prefix := ""
+		// Like coreutils, escape backslashes and newlines and mark the line with a backslash.
+		if strings.ContainsAny(name, "\\\n") {
+			prefix = "\\"
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		if _, err := fmt.Fprintf(bw, "%s%s  %s\n", prefix, entry.Checksum.Hex(), name); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteManifestFile atomically writes the entries to the manifest file.
+func WriteManifestFile(filePath string, entries []ManifestEntry) error {
+	var buf bytes.Buffer
+	if err := WriteManifest(&buf, entries); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filePath, buf.Bytes(), WithFileMode(0644))
+}
+
+// ReadManifest parses a manifest in the format of sha256sum and related tools.
+// Both text ("  ") and binary (" *") separators are accepted, and the algorithm
+// of each entry is detected from the checksum length.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		sumHex, name, ok := strings.Cut(line, " ")
+		if !ok || name == "" || (name[0] != ' ' && name[0] != '*') {
+			return nil, fmt.Errorf("invalid manifest line %d", lineNumber)
+		}
+		name = name[1:]
+		if escaped {
+			name = unescapeManifestPath(name)
+		}
+		sum, err := hex.DecodeString(sumHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum on manifest line %d: %w", lineNumber, err)
+		}
+		algorithm, ok := algorithmsBySize[len(sum)]
+		if !ok {
+			return nil, fmt.Errorf("unknown checksum length on manifest line %d", lineNumber)
+		}
+		entries = append(entries, ManifestEntry{Path: path.Clean(name), Checksum: sum, Algorithm: algorithm})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// ReadManifestFile parses the manifest file.
+func ReadManifestFile(filePath string) ([]ManifestEntry, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return ReadManifest(f)
+}
+
+// unescapeManifestPath reverses the escaping of backslashes and newlines.
+func unescapeManifestPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ManifestReport is the result of VerifyManifest. Paths are relative to the verified directory.
+type ManifestReport struct {
+	// Missing lists the files of the manifest that do not exist.
+	Missing []string
+	// Modified lists the files whose checksum does not match the manifest.
+	Modified []string
+	// Extra lists the files present in the directory but not in the manifest.
+	Extra []string
+}
+
+// OK reports whether the directory matches the manifest exactly.
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.Extra) == 0
+}
+
+// VerifyManifest checks the files of dir against the manifest file. If dir is empty,
+// the directory of the manifest is used, and the manifest itself is not reported as extra.
+// Entries with absolute or ".." paths are refused, and symbolic links are resolved within dir.
+//
+// Example:
+//
+//	report, err := VerifyManifest("backup/SHA256SUMS", "")
+//	if err != nil {
+//		return err
+//	}
+//	if !report.OK() {
+//		log.Printf("missing: %v, modified: %v, extra: %v", report.Missing, report.Modified, report.Extra)
+//	}
+func VerifyManifest(manifestFile, dir string) (*ManifestReport, error) {
+	entries, err := ReadManifestFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir = filepath.Dir(manifestFile)
+	}
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, rel := range files {
+		present[rel] = true
+	}
+	// Skip the manifest when it is stored in the verified directory.
+	if rel, err := filepath.Rel(dir, manifestFile); err == nil {
+		delete(present, filepath.ToSlash(rel))
+	}
+
+	report := &ManifestReport{}
+	listed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		// Manifests may be untrusted: never hash files outside dir.
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return nil, fmt.Errorf("illegal path in manifest: %q", entry.Path)
+		}
+		listed[entry.Path] = true
+		filePath, err := SecureJoin(dir, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := ChecksumFile(filePath, entry.Algorithm)
+		if errors.Is(err, fs.ErrNotExist) {
+			report.Missing = append(report.Missing, entry.Path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !sum.Equal(entry.Checksum) {
+			report.Modified = append(report.Modified, entry.Path)
+		}
+	}
+	for rel := range present {
+		if !listed[rel] {
+			report.Extra = append(report.Extra, rel)
+		}
+	}
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// manifestFiles returns the slash-separated relative paths of the regular files under dir.
+func manifestFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", dir, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,119 @@
+package goutils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		algorithm HashAlgorithm
+		expected  string
+	}{
+		{SHA256, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		{SHA1, "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"},
+		{MD5, "5eb63bbbe01eeed093cb22bb8f5acdc3"},
+		{CRC32, "0d4a1185"},
+	}
+	filePath := writeTestFile(t, t.TempDir(), "hello.txt", []byte("hello world"))
+	for _, tt := range tests {
+		sum, err := ChecksumFile(filePath, tt.algorithm)
+		if err != nil {
+			t.Fatalf("%s: error computing checksum: %v", tt.algorithm, err)
+		}
+		if sum.Hex() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.algorithm, tt.expected, sum.Hex())
+		}
+		ok, err := VerifyFileChecksum(filePath, tt.algorithm, sum.Base64())
+		if err != nil || !ok {
+			t.Errorf("%s: expected the base64 checksum to verify, got %v (%v)", tt.algorithm, ok, err)
+		}
+	}
+
+	if algorithm, err := ParseHashAlgorithm("SHA-512"); err != nil || algorithm != SHA512 {
+		t.Errorf("Expected sha512, got %q (%v)", algorithm, err)
+	}
+	if _, err := ChecksumReader(strings.NewReader(""), "sha3"); err == nil {
+		t.Errorf("Expected an error for an unsupported algorithm")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{
+		"a.txt":     "a",
+		"sub/b.txt": "b",
+		"sub/c.txt": "c",
+	})
+	entries, err := CreateManifest(dir, SHA256)
+	if err != nil {
+		t.Fatalf("Error creating manifest: %v", err)
+	}
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	if err := WriteManifestFile(manifest, entries); err != nil {
+		t.Fatalf("Error writing manifest: %v", err)
+	}
+
+	report, err := VerifyManifest(manifest, "")
+	if err != nil {
+		t.Fatalf("Error verifying manifest: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected the manifest to match, got %+v", report)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	createTestTree(t, dir, map[string]string{"sub/b.txt": "changed", "new.txt": "new"})
+	report, err = VerifyManifest(manifest, "")
+	if err != nil {
+		t.Fatalf("Error verifying manifest: %v", err)
+	}
+	if !equalStrings(report.Missing, []string{"a.txt"}) ||
+		!equalStrings(report.Modified, []string{"sub/b.txt"}) ||
+		!equalStrings(report.Extra, []string{"new.txt"}) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	outside := writeTestFile(t, filepath.Dir(dir), "outside.txt", []byte("secret"))
+	sum, err := ChecksumFile(outside, SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostile := filepath.Join(dir, "HOSTILE")
+	if err := WriteManifestFile(hostile, []ManifestEntry{{Path: "../outside.txt", Algorithm: SHA256, Checksum: sum}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyManifest(hostile, ""); err == nil || !strings.Contains(err.Error(), "illegal path") {
+		t.Errorf("Expected a ../ entry to be refused, got %v", err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	input := "d41d8cd98f00b204e9800998ecf8427e *./bin/empty\n" +
+		"\\0d4a1185  dir\\\\with\\nnewline\n"
+	entries, err := ReadManifest(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Error reading manifest: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "bin/empty" || entries[0].Algorithm != MD5 ||
+		entries[1].Path != "dir\\with\nnewline" || entries[1].Algorithm != CRC32 {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteManifest(&buf, entries[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "\\0d4a1185  dir\\\\with\\nnewline\n" {
+		t.Errorf("Unexpected escaped manifest line: %q", buf.String())
+	}
+
+	if _, err := ReadManifest(strings.NewReader("not a manifest\n")); err == nil {
+		t.Errorf("Expected an error for an invalid manifest")
+	}
+}
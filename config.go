@@ -0,0 +1,509 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigOption configures LoadConfig.
+type ConfigOption func(*configOptions)
+
+// configOptions holds the settings collected from ConfigOption values.
+type configOptions struct {
+	files         []configFile
+	envPrefix     string
+	envSet        bool
+	interpolation configInterpolation
+}
+
+// configFile is a configuration file to load.
+type configFile struct {
+	path     string
+	optional bool
+}
+
+// configInterpolation selects where ReplaceEnvVars is applied.
+type configInterpolation int
+
+const (
+	interpolateValues configInterpolation = iota
+	interpolateRaw
+	interpolateNone
+)
+
+// WithConfigFiles loads the files in order, later files overriding earlier ones.
+// The format is detected from the extension: .json, .ini, .conf or .cfg, and .env or
+// names starting with ".env" for dotenv files.
+func WithConfigFiles(paths ...string) ConfigOption {
+	return func(o *configOptions) {
+		for _, p := range paths {
+			o.files = append(o.files, configFile{path: p})
+		}
+	}
+}
+
+// WithOptionalConfigFiles is like WithConfigFiles, but skips the files that do not exist,
+// such as a local override file.
+func WithOptionalConfigFiles(paths ...string) ConfigOption {
+	return func(o *configOptions) {
+		for _, p := range paths {
+			o.files = append(o.files, configFile{path: p, optional: true})
+		}
+	}
+}
+
+// WithConfigEnv overrides the loaded values with the environment variables starting with
+// prefix, such as "APP_". The rest of the name is the key, where "__" separates nested
+// keys: APP_DATABASE__HOST sets database.host.
+func WithConfigEnv(prefix string) ConfigOption {
+	return func(o *configOptions) {
+		o.envPrefix = prefix
+		o.envSet = true
+	}
+}
+
+// WithRawInterpolation applies ReplaceEnvVars to the raw content of the files before decoding
+// them, instead of to the decoded string values. Values containing quotes or newlines may
+// then break the syntax of the file.
+func WithRawInterpolation() ConfigOption {
+	return func(o *configOptions) {
+		o.interpolation = interpolateRaw
+	}
+}
+
+// WithoutInterpolation disables ReplaceEnvVars.
+func WithoutInterpolation() ConfigOption {
+	return func(o *configOptions) {
+		o.interpolation = interpolateNone
+	}
+}
+
+// ConfigSources maps the dotted key of each loaded value, such as "database.host",
+// to its source: the file path, or "env:NAME" for environment variables.
+// Keys are lowercase.
+type ConfigSources map[string]string
+
+// LoadConfig loads configuration files and environment overrides into the struct pointed to by v.
+//
+// Values are applied in this order, each overriding the previous ones:
+//  1. the values already in v, as defaults;
+//  2. the files, in the order given;
+//  3. the environment variables selected by WithConfigEnv.
+//
+// Keys are matched to the struct fields like encoding/json does, case-insensitively, and string
+// values from dotenv, INI or environment sources are converted to the type of the field:
+// numbers, booleans, time.Duration (via ParseDuration) and comma-separated slices.
+// Unless disabled, ReplaceEnvVars is applied to the decoded string values of the files, except
+// dotenv files, which are interpolated by their parser so that quotes and escapes are honored
+// (see ParseDotenv).
+//
+// Example:
+//
+//	cfg := Config{Port: 8080}
+//	sources, err := LoadConfig(&cfg,
+//		WithConfigFiles("config.json"),
+//		WithOptionalConfigFiles("config.local.json"),
+//		WithConfigEnv("APP_"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	log.Printf("port %d from %s", cfg.Port, sources["port"])
+func LoadConfig(v any, opts ...ConfigOption) (ConfigSources, error) {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config target must be a pointer to a struct")
+	}
+	var o configOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tree := make(map[string]any)
+	sources := make(ConfigSources)
+	for _, file := range o.files {
+		values, err := readConfigFile(file.path, o.interpolation)
+		if err != nil {
+			if file.optional && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		mergeConfig(tree, values, "", file.path, sources)
+	}
+	if o.envSet {
+		for _, kv := range os.Environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			key, ok := strings.CutPrefix(name, o.envPrefix)
+			if !ok || key == "" {
+				continue
+			}
+			mergeConfig(tree, nestConfigKey(key, value), "", "env:"+name, sources)
+		}
+	}
+
+	coerced, err := coerceConfig(tree, target.Elem().Type(), "")
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(coerced)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return sources, nil
+}
+
+// readConfigFile reads and decodes a configuration file into a tree of lowercase keys.
+func readConfigFile(path string, interpolation configInterpolation) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if interpolation == interpolateRaw {
+		data = []byte(ReplaceEnvVars(string(data)))
+	}
+
+	var values map[string]any
+	// Dotenv files interpolate their own values, honoring quotes and escapes.
+	interpolated := false
+	base := strings.ToLower(filepath.Base(path))
+	switch ext := filepath.Ext(base); {
+	case ext == ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&values); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		values = lowerConfigKeys(values).(map[string]any)
+	case ext == ".env" || strings.HasPrefix(base, ".env"):
+		env, err := parseDotenv(data, interpolation == interpolateValues, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		interpolated = true
+		values = make(map[string]any)
+		for key, value := range env {
+			mergeConfig(values, nestConfigKey(key, value), "", "", nil)
+		}
+	case ext == ".ini" || ext == ".conf" || ext == ".cfg":
+		if values, err = parseINI(data); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", path)
+	}
+
+	if interpolation == interpolateValues && !interpolated {
+		interpolateConfig(values)
+	}
+	return values, nil
+}
+
+// nestConfigKey returns the tree setting an environment-style key such as DATABASE__HOST.
+func nestConfigKey(key, value string) map[string]any {
+	parts := strings.Split(strings.ToLower(key), "__")
+	tree := map[string]any{parts[len(parts)-1]: value}
+	for i := len(parts) - 2; i >= 0; i-- {
+		tree = map[string]any{parts[i]: tree}
+	}
+	return tree
+}
+
+// lowerConfigKeys returns the decoded JSON value with lowercase object keys.
+func lowerConfigKeys(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		lowered := make(map[string]any, len(v))
+		for key, child := range v {
+			lowered[strings.ToLower(key)] = lowerConfigKeys(child)
+		}
+		return lowered
+	case []any:
+		for i, child := range v {
+			v[i] = lowerConfigKeys(child)
+		}
+	}
+	return value
+}
+
+// interpolateConfig applies ReplaceEnvVars to the string values of the tree.
+func interpolateConfig(value any) any {
+	switch v := value.(type) {
+	case string:
+		return ReplaceEnvVars(v)
+	case map[string]any:
+		for key, child := range v {
+			v[key] = interpolateConfig(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = interpolateConfig(child)
+		}
+	}
+	return value
+}
+
+// mergeConfig merges src into dst, recording the source of each value set under prefix.
+// Objects are merged recursively; other values replace the existing ones.
+func mergeConfig(dst, src map[string]any, prefix, source string, sources ConfigSources) {
+	for key, value := range src {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if child, ok := value.(map[string]any); ok {
+			existing, ok := dst[key].(map[string]any)
+			if !ok {
+				existing = make(map[string]any)
+				dst[key] = existing
+				forgetConfigSources(sources, path)
+			}
+			mergeConfig(existing, child, path, source, sources)
+			continue
+		}
+		dst[key] = value
+		if sources != nil {
+			forgetConfigSources(sources, path)
+			sources[path] = source
+		}
+	}
+}
+
+// forgetConfigSources removes the sources of the value at path and of the values below it.
+func forgetConfigSources(sources ConfigSources, path string) {
+	for key := range sources {
+		if key == path || strings.HasPrefix(key, path+".") {
+			delete(sources, key)
+		}
+	}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// coerceConfig converts the string values of the tree to the types of the fields of t.
+func coerceConfig(value any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return value, nil
+	}
+	invalid := func(err error) error {
+		return fmt.Errorf("invalid config value for %s: %w", path, err)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return value, nil
+		}
+		var errs []error
+		for key, child := range values {
+			field, ok := configField(t, key)
+			if !ok {
+				continue
+			}
+			var err error
+			if values[key], err = coerceConfig(child, field.Type, joinConfigPath(path, key)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return values, errors.Join(errs...)
+	case reflect.Map:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return value, nil
+		}
+		var errs []error
+		for key, child := range values {
+			var err error
+			if values[key], err = coerceConfig(child, t.Elem(), joinConfigPath(path, key)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return values, errors.Join(errs...)
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]any)
+		if s, isString := value.(string); isString {
+			if t.Elem().Kind() == reflect.Uint8 {
+				// []byte is decoded from base64 strings.
+				return value, nil
+			}
+			items = nil
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			ok = true
+		}
+		if !ok {
+			return value, nil
+		}
+		var errs []error
+		for i, item := range items {
+			var err error
+			if items[i], err = coerceConfig(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return items, errors.Join(errs...)
+	case reflect.String:
+		switch v := value.(type) {
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		return value, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	s = strings.TrimSpace(s)
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t == durationType {
+			d, err := ParseDuration(s)
+			if err != nil {
+				return nil, invalid(err)
+			}
+			return int64(d), nil
+		}
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return f, nil
+	}
+	return value, nil
+}
+
+// joinConfigPath returns the dotted path of key under path.
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// configField returns the field of the struct type t decoded from key by encoding/json,
+// matching names case-insensitively and looking into embedded structs.
+func configField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if f, ok := configField(embedded, key); ok {
+					return f, true
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// parseINI decodes an INI file into a tree of lowercase keys, where "[a.b]" sections nest.
+func parseINI(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	section := values
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", n, line)
+			}
+			section = values
+			for _, part := range strings.Split(strings.ToLower(line[1:len(line)-1]), ".") {
+				part = strings.TrimSpace(part)
+				child, ok := section[part].(map[string]any)
+				if !ok {
+					child = make(map[string]any)
+					section[part] = child
+				}
+				section = child
+			}
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		section[key] = unquoteConfigValue(strings.TrimSpace(line[i+1:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// unquoteConfigValue removes matching single or double quotes around a value.
+func unquoteConfigValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
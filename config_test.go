@@ -0,0 +1,151 @@
+package goutils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDatabaseConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password"`
+}
+
+type testConfig struct {
+	Name     string             `json:"name"`
+	Port     int                `json:"port"`
+	Debug    bool               `json:"debug"`
+	Timeout  time.Duration      `json:"timeout"`
+	Origins  []string           `json:"origins"`
+	Database testDatabaseConfig `json:"database"`
+	Limits   map[string]int     `json:"limits"`
+	MaxConns int                `json:"max_conns"`
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTestFile(t, dir, "config.json", []byte(`{
+		"name": "app",
+		"port": 8080,
+		"origins": ["https://a.example"],
+		"database": {"host": "localhost", "port": 5432, "password": "${TEST_CONFIG_DB_PASSWORD}"},
+		"limits": {"requests": 100}
+	}`))
+	ini := writeTestFile(t, dir, "override.ini", []byte(`
+; overrides
+timeout = 30s
+[database]
+host = "db.internal"
+`))
+	dotenv := writeTestFile(t, dir, ".env", []byte("DEBUG=true\nLIMITS__REQUESTS=200\n"))
+	t.Setenv("TEST_CONFIG_DB_PASSWORD", "secret")
+	t.Setenv("TESTAPP_PORT", "9090")
+	t.Setenv("TESTAPP_DATABASE__PORT", "6432")
+	t.Setenv("TESTAPP_ORIGINS", "https://b.example, https://c.example")
+	t.Setenv("TESTAPP_MAX_CONNS", "50")
+
+	cfg := testConfig{Name: "default", Timeout: time.Second}
+	sources, err := LoadConfig(&cfg,
+		WithConfigFiles(base, ini, dotenv),
+		WithOptionalConfigFiles(filepath.Join(dir, "missing.json")),
+		WithConfigEnv("TESTAPP_"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := testConfig{
+		Name:     "app",
+		Port:     9090,
+		Debug:    true,
+		Timeout:  30 * time.Second,
+		Origins:  []string{"https://b.example", "https://c.example"},
+		Database: testDatabaseConfig{Host: "db.internal", Port: 6432, Password: "secret"},
+		Limits:   map[string]int{"requests": 200},
+		MaxConns: 50,
+	}
+	if cfg.Name != want.Name || cfg.Port != want.Port || cfg.Debug != want.Debug || cfg.Timeout != want.Timeout ||
+		!equalStrings(cfg.Origins, want.Origins) || cfg.Database != want.Database ||
+		cfg.Limits["requests"] != 200 || cfg.MaxConns != want.MaxConns {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+
+	for key, source := range map[string]string{
+		"name":              base,
+		"port":              "env:TESTAPP_PORT",
+		"timeout":           ini,
+		"debug":             dotenv,
+		"database.host":     ini,
+		"database.port":     "env:TESTAPP_DATABASE__PORT",
+		"database.password": base,
+		"limits.requests":   dotenv,
+	} {
+		if sources[key] != source {
+			t.Errorf("source of %s: expected %s, got %s", key, source, sources[key])
+		}
+	}
+}
+
+func TestLoadConfigInterpolation(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "config.json", []byte(`{"name": "${TEST_CONFIG_NAME}", "port": ${TEST_CONFIG_PORT}}`))
+	t.Setenv("TEST_CONFIG_NAME", `quoted "name"`)
+	t.Setenv("TEST_CONFIG_PORT", "8081")
+
+	// The port placeholder is not valid JSON until it is replaced in the raw content.
+	var cfg testConfig
+	if _, err := LoadConfig(&cfg, WithConfigFiles(path)); err == nil {
+		t.Error("expected an error decoding the placeholder")
+	}
+	if _, err := LoadConfig(&cfg, WithConfigFiles(path), WithRawInterpolation()); err == nil {
+		t.Error("expected an error for a value breaking the JSON syntax")
+	}
+	t.Setenv("TEST_CONFIG_NAME", "raw")
+	if _, err := LoadConfig(&cfg, WithConfigFiles(path), WithRawInterpolation()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "raw" || cfg.Port != 8081 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	path = writeTestFile(t, dir, "values.json", []byte(`{"name": "${TEST_CONFIG_NAME}"}`))
+	cfg = testConfig{}
+	if _, err := LoadConfig(&cfg, WithConfigFiles(path), WithoutInterpolation()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "${TEST_CONFIG_NAME}" {
+		t.Errorf("expected no interpolation, got %q", cfg.Name)
+	}
+
+	// Single-quoted and escaped dotenv values stay literal.
+	dotenv := writeTestFile(t, dir, ".env", []byte("NAME='${TEST_CONFIG_NAME}'\nDATABASE__HOST=\"\\${TEST_CONFIG_NAME}\"\nDATABASE__PASSWORD=${TEST_CONFIG_NAME}\n"))
+	cfg = testConfig{}
+	if _, err := LoadConfig(&cfg, WithConfigFiles(dotenv)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "${TEST_CONFIG_NAME}" || cfg.Database.Host != "${TEST_CONFIG_NAME}" || cfg.Database.Password != "raw" {
+		t.Errorf("unexpected dotenv interpolation %q %q %q", cfg.Name, cfg.Database.Host, cfg.Database.Password)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	var cfg testConfig
+	if _, err := LoadConfig(cfg); err == nil {
+		t.Error("expected an error for a non-pointer target")
+	}
+	if _, err := LoadConfig(&cfg, WithConfigFiles(filepath.Join(dir, "missing.json"))); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	yaml := writeTestFile(t, dir, "config.yaml", []byte("port: 1"))
+	if _, err := LoadConfig(&cfg, WithConfigFiles(yaml)); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+	bad := writeTestFile(t, dir, "bad.env", []byte("PORT=abc\nDEBUG=maybe\n"))
+	_, err := LoadConfig(&cfg, WithConfigFiles(bad))
+	if err == nil || !strings.Contains(err.Error(), "port") || !strings.Contains(err.Error(), "debug") {
+		t.Errorf("expected errors for port and debug, got %v", err)
+	}
+}
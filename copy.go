@@ -0,0 +1,307 @@
+package goutils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Preserve selects the file metadata kept by CopyFileWithOptions.
+type Preserve int
+
+const (
+	// PreserveMode keeps the permission bits, including setuid, setgid and sticky bits.
+	PreserveMode Preserve = 1 << iota
+	// PreserveTimes keeps the modification time.
+	PreserveTimes
+	// PreserveOwner keeps the user and group, which usually requires privileges.
+	PreserveOwner
+	// PreserveAll keeps the mode, modification time and owner.
+	PreserveAll = PreserveMode | PreserveTimes | PreserveOwner
+)
+
+// SymlinkPolicy defines how symbolic links are copied.
+type SymlinkPolicy int
+
+const (
+	// SymlinkFollow copies the file the link points to.
+	SymlinkFollow SymlinkPolicy = iota
+	// SymlinkCopy recreates the link itself at the destination.
+	SymlinkCopy
+	// SymlinkSkip ignores symbolic links.
+	SymlinkSkip
+)
+
+// CopyOption configures CopyFileWithOptions.
+type CopyOption func(*copyOptions)
+
+// copyOptions holds the settings collected from CopyOption values.
+type copyOptions struct {
+	preserve  Preserve
+	symlinks  SymlinkPolicy
+	overwrite bool
+	atomic    bool
+	verify    bool
+	// Directory options, see CopyDir.
+	include     globList
+	exclude     globList
+	concurrency int
+	dryRun      func(FileOperation)
+	// err records an invalid option, returned before any work is done.
+	err error
+}
+
+// WithPreserve keeps the selected metadata of the source file.
+func WithPreserve(preserve Preserve) CopyOption {
+	return func(o *copyOptions) {
+		o.preserve |= preserve
+	}
+}
+
+// WithSymlinks sets how a symbolic link source is copied. Defaults to SymlinkFollow.
+func WithSymlinks(policy SymlinkPolicy) CopyOption {
+	return func(o *copyOptions) {
+		o.symlinks = policy
+	}
+}
+
+// WithOverwrite sets whether an existing destination may be replaced. Defaults to true;
+// when false, copying onto an existing file fails with an error wrapping fs.ErrExist.
+func WithOverwrite(overwrite bool) CopyOption {
+	return func(o *copyOptions) {
+		o.overwrite = overwrite
+	}
+}
+
+// WithAtomicCopy writes the destination through a temporary file renamed into place,
+// so readers never observe a partially copied file.
+func WithAtomicCopy() CopyOption {
+	return func(o *copyOptions) {
+		o.atomic = true
+	}
+}
+
+// WithVerify compares the SHA-256 checksums of the source and destination after copying.
+func WithVerify() CopyOption {
+	return func(o *copyOptions) {
+		o.verify = true
+	}
+}
+
+// CopyFileWithOptions copies a file from src to dst with the given options.
+//
+// The content is copied between *os.File values, so the kernel fast paths
+// (copy_file_range and sendfile on Linux) are used when available.
+//
+// Example:
+//
+//	err := CopyFileWithOptions("app.conf", "backup/app.conf",
+//		WithPreserve(PreserveMode|PreserveTimes),
+//		WithOverwrite(false),
+//		WithAtomicCopy(),
+//		WithVerify(),
+//	)
+func CopyFileWithOptions(src, dst string, opts ...CopyOption) error {
+	o := copyOptions{overwrite: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return copyFile(src, dst, o)
+}
+
+// copyFile copies a single file according to the options.
+func copyFile(src, dst string, o copyOptions) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		switch o.symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkCopy:
+			return copySymlink(src, dst, info, o)
+		default:
+			if info, err = os.Stat(src); err != nil {
+				return fmt.Errorf("failed to open source file: %w", err)
+			}
+		}
+	}
+	if info.IsDir() {
+		return fmt.Errorf("source %s is a directory", src)
+	}
+
+	if o.atomic {
+		err = copyFileAtomic(src, dst, info, o)
+	} else {
+		err = copyFileDirect(src, dst, info, o)
+	}
+	if err != nil {
+		return err
+	}
+	if err := applyMetadata(dst, info, o.preserve); err != nil {
+		return err
+	}
+	if o.verify {
+		return verifyCopy(src, dst)
+	}
+	return nil
+}
+
+// copyFileDirect copies the content into dst, created or truncated in place.
+func copyFileDirect(src, dst string, info fs.FileInfo, o copyOptions) (err error) {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func(sourceFile *os.File) {
+		_ = sourceFile.Close()
+	}(sourceFile)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !o.overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	mode := fs.FileMode(0666)
+	if o.preserve&PreserveMode != 0 {
+		mode = info.Mode().Perm()
+	}
+	destinationFile, err := os.OpenFile(dst, flags, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer func(destinationFile *os.File) {
+		if closeErr := destinationFile.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close destination file: %w", closeErr)
+		}
+	}(destinationFile)
+
+	if _, err = io.Copy(destinationFile, sourceFile); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err = destinationFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync destination file: %w", err)
+	}
+	return nil
+}
+
+// copyFileAtomic copies the content into a temporary file renamed over dst.
+func copyFileAtomic(src, dst string, info fs.FileInfo, o copyOptions) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func(sourceFile *os.File) {
+		_ = sourceFile.Close()
+	}(sourceFile)
+
+	// Like copyFileDirect, a new file gets 0666 minus the umask and an existing one keeps its mode.
+	writeOpts := []WriteOption{withCreateMode(0666)}
+	if o.preserve&PreserveMode != 0 {
+		writeOpts = append(writeOpts, WithFileMode(info.Mode().Perm()))
+	}
+	if !o.overwrite {
+		writeOpts = append(writeOpts, withNoReplace())
+	}
+	w, err := NewAtomicWriter(dst, writeOpts...)
+	if err != nil {
+		return err
+	}
+	defer func(w *AtomicWriter) {
+		_ = w.Close()
+	}(w)
+
+	if _, err := io.Copy(w.file, sourceFile); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return w.Commit()
+}
+
+// copySymlink recreates the symbolic link src at dst, replacing dst atomically if allowed.
+func copySymlink(src, dst string, info fs.FileInfo, o copyOptions) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("failed to read symbolic link: %w", err)
+	}
+	if !o.overwrite {
+		if err := os.Symlink(target, dst); err != nil {
+			return fmt.Errorf("failed to create symbolic link: %w", err)
+		}
+	} else {
+		tmp, err := symlinkTemp(target, dst)
+		if err != nil {
+			return fmt.Errorf("failed to create symbolic link: %w", err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("failed to create symbolic link: %w", err)
+		}
+	}
+	if o.preserve&PreserveOwner != 0 {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err := os.Lchown(dst, uid, gid); err != nil {
+				return fmt.Errorf("failed to preserve owner: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// symlinkTemp creates a symbolic link to target with an unused random name next to dst,
+// and returns its path.
+func symlinkTemp(target, dst string) (string, error) {
+	dir, base := filepath.Split(dst)
+	for range 100 {
+		tmp := filepath.Join(dir, "."+base+".tmp-"+rand.Text())
+		err := os.Symlink(target, tmp)
+		if !errors.Is(err, fs.ErrExist) {
+			return tmp, err
+		}
+	}
+	return "", fmt.Errorf("failed to find an unused temporary name for %s", dst)
+}
+
+// applyMetadata copies the selected metadata of info to dst.
+func applyMetadata(dst string, info fs.FileInfo, preserve Preserve) error {
+	if preserve&PreserveOwner != 0 {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err := os.Lchown(dst, uid, gid); err != nil {
+				return fmt.Errorf("failed to preserve owner: %w", err)
+			}
+		}
+	}
+	// Set the mode after the owner, since chown clears the setuid and setgid bits.
+	if preserve&PreserveMode != 0 {
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := os.Chmod(dst, mode); err != nil {
+			return fmt.Errorf("failed to preserve mode: %w", err)
+		}
+	}
+	if preserve&PreserveTimes != 0 {
+		if err := os.Chtimes(dst, time.Time{}, info.ModTime()); err != nil {
+			return fmt.Errorf("failed to preserve modification time: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyCopy compares the SHA-256 checksums of src and dst.
+func verifyCopy(src, dst string) error {
+	srcSum, err := ChecksumFile(src, SHA256)
+	if err != nil {
+		return err
+	}
+	dstSum, err := ChecksumFile(dst, SHA256)
+	if err != nil {
+		return err
+	}
+	if !srcSum.Equal(dstSum) {
+		return errors.New("checksum mismatch between source and destination")
+	}
+	return nil
+}
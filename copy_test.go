@@ -0,0 +1,119 @@
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "src.txt", []byte("Hello, World!"))
+	dst := filepath.Join(dir, "dst.txt")
+
+	if err := CopyFile(src, dst); err != nil {
+		t.Fatalf("Error copying file: %v", err)
+	}
+	data, _ := os.ReadFile(dst)
+	if string(data) != "Hello, World!" {
+		t.Errorf("Unexpected content: %s", data)
+	}
+	if err := CopyFile(dir, filepath.Join(dir, "copy")); err == nil {
+		t.Errorf("Expected an error when copying a directory")
+	}
+}
+
+func TestCopyFileWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, dir, "script.sh", []byte("#!/bin/sh\necho hello\n"))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chmod(src, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, atomic := range []bool{false, true} {
+		dst := filepath.Join(dir, "copy.sh")
+		_ = os.Remove(dst)
+		opts := []CopyOption{WithPreserve(PreserveMode | PreserveTimes), WithVerify()}
+		if atomic {
+			opts = append(opts, WithAtomicCopy())
+		}
+		if err := CopyFileWithOptions(src, dst, opts...); err != nil {
+			t.Fatalf("Error copying file (atomic=%v): %v", atomic, err)
+		}
+		info, err := os.Stat(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0750 {
+			t.Errorf("Expected mode 0750 (atomic=%v), got %v", atomic, info.Mode().Perm())
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("Expected mtime %v (atomic=%v), got %v", mtime, atomic, info.ModTime())
+		}
+
+		err = CopyFileWithOptions(src, dst, append(opts, WithOverwrite(false))...)
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Expected fs.ErrExist (atomic=%v), got %v", atomic, err)
+		}
+	}
+
+	// Without PreserveMode, an atomic copy gets the same mode as a plain one.
+	plain, atomic := filepath.Join(dir, "plain.sh"), filepath.Join(dir, "atomic.sh")
+	if err := CopyFileWithOptions(src, plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFileWithOptions(src, atomic, WithAtomicCopy()); err != nil {
+		t.Fatal(err)
+	}
+	plainInfo, err := os.Stat(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomicInfo, err := os.Stat(atomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainInfo.Mode().Perm() != atomicInfo.Mode().Perm() {
+		t.Errorf("Expected mode %v for the atomic copy, got %v", plainInfo.Mode().Perm(), atomicInfo.Mode().Perm())
+	}
+}
+
+func TestCopyFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := writeTestFile(t, dir, "target.txt", []byte("target"))
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+
+	dst := filepath.Join(dir, "link-copy")
+	if err := CopyFileWithOptions(link, dst, WithSymlinks(SymlinkCopy)); err != nil {
+		t.Fatalf("Error copying link: %v", err)
+	}
+	if dest, err := os.Readlink(dst); err != nil || dest != "target.txt" {
+		t.Errorf("Expected a link to target.txt, got %q (%v)", dest, err)
+	}
+
+	followed := filepath.Join(dir, "followed")
+	if err := CopyFileWithOptions(link, followed); err != nil {
+		t.Fatalf("Error copying link target: %v", err)
+	}
+	if info, _ := os.Lstat(followed); info.Mode()&fs.ModeSymlink != 0 {
+		t.Errorf("Expected a regular file copy of %s", target)
+	}
+
+	skipped := filepath.Join(dir, "skipped")
+	if err := CopyFileWithOptions(link, skipped, WithSymlinks(SymlinkSkip)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(skipped); !os.IsNotExist(err) {
+		t.Errorf("Expected the link to be skipped")
+	}
+}
@@ -0,0 +1,378 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileOperation describes an operation performed, or planned in dry-run mode,
+// by CopyDir and MoveDir.
+type FileOperation struct {
+	Op          string // "mkdir", "copy", "symlink", "rename" or "remove"
+	Source      string
+	Destination string
+}
+
+// WithInclude only copies files whose path relative to the source directory, or base name,
+// matches one of the patterns (glob syntax, see GlobPattern). Directories are always traversed.
+// A malformed pattern makes CopyDir and MoveDir fail with an error wrapping path.ErrBadPattern.
+func WithInclude(patterns ...string) CopyOption {
+	return func(o *copyOptions) {
+		o.include = appendGlobs(o.include, patterns, &o.err)
+	}
+}
+
+// WithExclude skips files and directories whose path relative to the source directory,
+// or base name, matches one of the patterns (glob syntax, see GlobPattern).
+// A malformed pattern makes CopyDir and MoveDir fail with an error wrapping path.ErrBadPattern.
+func WithExclude(patterns ...string) CopyOption {
+	return func(o *copyOptions) {
+		o.exclude = appendGlobs(o.exclude, patterns, &o.err)
+	}
+}
+
+// WithConcurrency sets the number of files copied in parallel by CopyDir. Defaults to 1.
+func WithConcurrency(n int) CopyOption {
+	return func(o *copyOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithDryRun reports each intended operation to report instead of performing it.
+func WithDryRun(report func(FileOperation)) CopyOption {
+	return func(o *copyOptions) {
+		o.dryRun = report
+	}
+}
+
+// CopyDir recursively copies the directory src to dst.
+//
+// Symbolic links are recreated as links unless WithSymlinks selects another policy;
+// with SymlinkFollow, links to directories are traversed once, so cycles are not followed.
+// All CopyFileWithOptions options apply to the copied files, and WithPreserve also
+// applies to directories.
+//
+// Example:
+//
+//	err := CopyDir("templates", "/srv/site",
+//		WithExclude(".git", "*.tmp"),
+//		WithPreserve(PreserveMode|PreserveTimes),
+//		WithConcurrency(8),
+//	)
+func CopyDir(src, dst string, opts ...CopyOption) error {
+	o := copyOptions{overwrite: true, symlinks: SymlinkCopy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.err != nil {
+		return o.err
+	}
+	_, err := copyDir(src, dst, o)
+	return err
+}
+
+// MoveDir moves the directory src to dst.
+//
+// Without filters, the directory is renamed, falling back to a copy followed by the removal
+// of src when the rename fails across filesystems. With WithInclude or WithExclude, only
+// the matching files are moved, and source directories left empty are removed.
+func MoveDir(src, dst string, opts ...CopyOption) error {
+	o := copyOptions{overwrite: true, symlinks: SymlinkCopy, preserve: PreserveMode | PreserveTimes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.err != nil {
+		return o.err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source %s is not a directory", src)
+	}
+
+	filtered := len(o.include) > 0 || len(o.exclude) > 0
+	if !filtered {
+		if o.dryRun != nil {
+			o.dryRun(FileOperation{Op: "rename", Source: src, Destination: dst})
+			return nil
+		}
+		if !o.overwrite {
+			if _, err := os.Lstat(dst); err == nil {
+				return fmt.Errorf("failed to move directory: %w: %s", fs.ErrExist, dst)
+			}
+		}
+		err := os.Rename(src, dst)
+		if err == nil || !isCrossDevice(err) {
+			return err
+		}
+	}
+
+	copied, err := copyDir(src, dst, o)
+	if err != nil {
+		return err
+	}
+	if !filtered {
+		if o.dryRun != nil {
+			o.dryRun(FileOperation{Op: "remove", Source: src})
+			return nil
+		}
+		return os.RemoveAll(src)
+	}
+	return removeMoved(src, copied, o)
+}
+
+// removeMoved removes the moved files, then the source directories left empty.
+func removeMoved(src string, copied []string, o copyOptions) error {
+	// The copied paths are cleaned by filepath.Join, so compare them with a clean source.
+	src = filepath.Clean(src)
+	dirs := make(map[string]struct{})
+	for _, file := range copied {
+		if o.dryRun != nil {
+			o.dryRun(FileOperation{Op: "remove", Source: file})
+		} else if err := os.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove moved file: %w", err)
+		}
+		for dir := filepath.Dir(file); isSubdir(src, dir); dir = filepath.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	if o.dryRun != nil {
+		return nil
+	}
+	// Remove the deepest directories first; non-empty directories are kept.
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	for _, dir := range sorted {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
+
+// isSubdir reports whether dir is strictly below root.
+func isSubdir(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// dirCopier holds the state of a recursive copy.
+type dirCopier struct {
+	o       copyOptions
+	root    string
+	target  string
+	jobs    chan FileOperation
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	errs    []error
+	copied  []string
+	dirs    []dirMetadata
+	visited map[string]bool
+}
+
+// dirMetadata is a copied directory whose metadata is applied once its content is copied.
+type dirMetadata struct {
+	path string
+	info fs.FileInfo
+}
+
+// copyDir copies src to dst and returns the source paths of the copied files.
+func copyDir(src, dst string, o copyOptions) ([]string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source %s is not a directory", src)
+	}
+
+	c := &dirCopier{o: o, root: src, jobs: make(chan FileOperation), visited: make(map[string]bool)}
+	// Never copy the destination into itself when it is inside the source.
+	if target, err := filepath.Abs(dst); err == nil {
+		c.target = target
+	}
+	workers := max(o.concurrency, 1)
+	for range workers {
+		c.wg.Add(1)
+		go c.worker()
+	}
+	c.walk(src, dst, info)
+	close(c.jobs)
+	c.wg.Wait()
+
+	// Apply directory metadata last, since copying files updates the modification times.
+	if o.dryRun == nil {
+		for i := len(c.dirs) - 1; i >= 0; i-- {
+			if err := applyMetadata(c.dirs[i].path, c.dirs[i].info, o.preserve); err != nil {
+				c.fail(err)
+			}
+		}
+	}
+	sort.Strings(c.copied)
+	return c.copied, errors.Join(c.errs...)
+}
+
+// walk copies the directory src, described by info, to dst.
+func (c *dirCopier) walk(src, dst string, info fs.FileInfo) {
+	if real, err := filepath.EvalSymlinks(src); err == nil {
+		if c.visited[real] {
+			return
+		}
+		c.visited[real] = true
+	}
+	if !c.mkdir(src, dst, info) {
+		return
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to read directory %s: %w", src, err))
+		return
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		rel, _ := filepath.Rel(c.root, srcPath)
+		if c.excluded(rel) {
+			continue
+		}
+		if abs, err := filepath.Abs(srcPath); err == nil && abs == c.target {
+			continue
+		}
+
+		entryInfo, err := os.Lstat(srcPath)
+		if err != nil {
+			c.fail(err)
+			continue
+		}
+		if entryInfo.Mode()&fs.ModeSymlink != 0 {
+			switch c.o.symlinks {
+			case SymlinkSkip:
+				continue
+			case SymlinkCopy:
+				c.dispatch(FileOperation{Op: "symlink", Source: srcPath, Destination: dstPath}, rel)
+				continue
+			default:
+				if entryInfo, err = os.Stat(srcPath); err != nil {
+					c.fail(fmt.Errorf("failed to follow symbolic link %s: %w", srcPath, err))
+					continue
+				}
+			}
+		}
+		if entryInfo.IsDir() {
+			c.walk(srcPath, dstPath, entryInfo)
+			continue
+		}
+		c.dispatch(FileOperation{Op: "copy", Source: srcPath, Destination: dstPath}, rel)
+	}
+}
+
+// mkdir creates the destination directory and reports whether the walk may continue.
+func (c *dirCopier) mkdir(src, dst string, info fs.FileInfo) bool {
+	if c.o.dryRun != nil {
+		c.o.dryRun(FileOperation{Op: "mkdir", Source: src, Destination: dst})
+		return true
+	}
+	if err := os.MkdirAll(dst, info.Mode().Perm()|0700); err != nil {
+		c.fail(fmt.Errorf("failed to create directory %s: %w", dst, err))
+		return false
+	}
+	c.dirs = append(c.dirs, dirMetadata{path: dst, info: info})
+	return true
+}
+
+// dispatch sends a file operation to the workers, unless it is filtered out.
+func (c *dirCopier) dispatch(op FileOperation, rel string) {
+	if !c.included(rel) {
+		return
+	}
+	if c.o.dryRun != nil {
+		c.o.dryRun(op)
+		c.copied = append(c.copied, op.Source)
+		return
+	}
+	c.jobs <- op
+}
+
+// worker copies the files received from the jobs channel.
+func (c *dirCopier) worker() {
+	defer c.wg.Done()
+	fileOpts := c.o
+	fileOpts.symlinks = SymlinkFollow
+	for op := range c.jobs {
+		var err error
+		if op.Op == "symlink" {
+			var info fs.FileInfo
+			if info, err = os.Lstat(op.Source); err == nil {
+				err = copySymlink(op.Source, op.Destination, info, c.o)
+			}
+		} else {
+			err = copyFile(op.Source, op.Destination, fileOpts)
+		}
+		if err != nil {
+			c.fail(fmt.Errorf("failed to copy %s: %w", op.Source, err))
+			continue
+		}
+		c.mu.Lock()
+		c.copied = append(c.copied, op.Source)
+		c.mu.Unlock()
+	}
+}
+
+// fail records an error.
+func (c *dirCopier) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// excluded reports whether the relative path matches an exclude pattern.
+func (c *dirCopier) excluded(rel string) bool {
+	return c.o.exclude.match(rel)
+}
+
+// included reports whether the relative file path matches an include pattern, if any.
+func (c *dirCopier) included(rel string) bool {
+	return len(c.o.include) == 0 || c.o.include.match(rel)
+}
+
+// globList is a list of include or exclude patterns, compiled once.
+type globList []*GlobPattern
+
+// appendGlobs compiles the patterns and appends them to l, recording the first malformed
+// one in errp.
+func appendGlobs(l globList, patterns []string, errp *error) globList {
+	for _, pattern := range patterns {
+		g, err := CompileGlob(pattern)
+		if err != nil {
+			if *errp == nil {
+				*errp = fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			continue
+		}
+		l = append(l, g)
+	}
+	return l
+}
+
+// match reports whether the slash-separated relative path, or its base name,
+// matches one of the patterns.
+func (l globList) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	for _, g := range l {
+		if g.Match(rel) || g.Match(base) {
+			return true
+		}
+	}
+	return false
+}
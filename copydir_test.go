@@ -0,0 +1,152 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// createTestTree creates files (relative path -> content) under dir.
+func createTestTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listTestTree returns the sorted slash-separated relative paths of the files under dir.
+func listTestTree(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestCopyDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	createTestTree(t, src, map[string]string{
+		"a.txt":           "a",
+		"b.tmp":           "b",
+		"sub/c.txt":       "c",
+		"sub/deep/d.json": "d",
+		".git/config":     "git",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := CopyDir(src, dst, WithExclude(".git", "*.tmp"), WithConcurrency(4), WithPreserve(PreserveMode|PreserveTimes))
+	if err != nil {
+		t.Fatalf("Error copying directory: %v", err)
+	}
+	expected := []string{"a.txt", "link", "sub/c.txt", "sub/deep/d.json"}
+	if files := listTestTree(t, dst); !equalStrings(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "a.txt" {
+		t.Errorf("Expected the link to be copied as a link, got %q (%v)", target, err)
+	}
+
+	included := filepath.Join(t.TempDir(), "json")
+	if err := CopyDir(src, included, WithInclude("*.json")); err != nil {
+		t.Fatalf("Error copying directory: %v", err)
+	}
+	if files := listTestTree(t, included); !equalStrings(files, []string{"sub/deep/d.json"}) {
+		t.Errorf("Expected only the JSON file, got %v", files)
+	}
+
+	var ops []FileOperation
+	dryRun := filepath.Join(t.TempDir(), "dry-run")
+	if err := CopyDir(src, dryRun, WithDryRun(func(op FileOperation) { ops = append(ops, op) })); err != nil {
+		t.Fatalf("Error in dry run: %v", err)
+	}
+	if len(ops) != 10 {
+		t.Errorf("Expected 10 operations, got %d: %v", len(ops), ops)
+	}
+	if _, err := os.Stat(dryRun); !os.IsNotExist(err) {
+		t.Errorf("Expected the dry run to create nothing")
+	}
+
+	malformed := filepath.Join(t.TempDir(), "malformed")
+	if err := CopyDir(src, malformed, WithExclude("{secrets")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Expected path.ErrBadPattern, got %v", err)
+	}
+	if err := MoveDir(src, malformed, WithInclude("[")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Expected path.ErrBadPattern, got %v", err)
+	}
+	if FolderExists(malformed) || !FolderExists(src) {
+		t.Errorf("Expected nothing to be copied or moved with a malformed pattern")
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	base := t.TempDir()
+	src := filepath.Join(base, "src")
+	createTestTree(t, src, map[string]string{
+		"keep/a.log": "a",
+		"move/b.txt": "b",
+		"c.txt":      "c",
+	})
+
+	// An unclean source path still has its emptied directories removed.
+	partial := filepath.Join(base, "txt")
+	if err := MoveDir(src+string(filepath.Separator)+".", partial, WithInclude("*.txt")); err != nil {
+		t.Fatalf("Error moving files: %v", err)
+	}
+	if files := listTestTree(t, partial); !equalStrings(files, []string{"c.txt", "move/b.txt"}) {
+		t.Errorf("Unexpected moved files: %v", files)
+	}
+	if files := listTestTree(t, src); !equalStrings(files, []string{"keep/a.log"}) {
+		t.Errorf("Unexpected remaining files: %v", files)
+	}
+	if FolderExists(filepath.Join(src, "move")) {
+		t.Errorf("Expected the emptied directory to be removed")
+	}
+
+	dst := filepath.Join(base, "dst")
+	if err := MoveDir(src, dst); err != nil {
+		t.Fatalf("Error moving directory: %v", err)
+	}
+	if FolderExists(src) || !FileExists(filepath.Join(dst, "keep", "a.log")) {
+		t.Errorf("Expected the directory to be moved")
+	}
+	if err := MoveDir(partial, dst, WithOverwrite(false)); err == nil {
+		t.Errorf("Expected an error when the destination exists")
+	}
+}
+
+// equalStrings reports whether two string slices are equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
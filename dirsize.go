@@ -0,0 +1,289 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrLowDiskSpace is returned by CheckDiskSpace when the free space is below a threshold.
+var ErrLowDiskSpace = errors.New("low disk space")
+
+// DirSizeOption configures DirSize.
+type DirSizeOption func(*dirSizeOptions)
+
+// dirSizeOptions holds the settings collected from DirSizeOption values.
+type dirSizeOptions struct {
+	symlinks    SymlinkPolicy
+	concurrency int
+}
+
+// WithDirSizeSymlinks sets how symbolic links are counted: SymlinkCopy counts the link
+// itself (the default), SymlinkFollow counts its target, and SymlinkSkip ignores it.
+// Followed directories are traversed once, so cycles are not followed.
+func WithDirSizeSymlinks(policy SymlinkPolicy) DirSizeOption {
+	return func(o *dirSizeOptions) {
+		o.symlinks = policy
+	}
+}
+
+// WithDirSizeConcurrency sets the number of directories read in parallel. Defaults to 1.
+func WithDirSizeConcurrency(n int) DirSizeOption {
+	return func(o *dirSizeOptions) {
+		o.concurrency = n
+	}
+}
+
+// DirSizeInfo is the result of DirSize.
+type DirSizeInfo struct {
+	// Bytes is the total size of the files, counting hard-linked files once.
+	Bytes    int64
+	Files    int64
+	Dirs     int64
+	Symlinks int64
+}
+
+// String returns the size formatted with ConvertBytes, and the number of files and directories.
+func (s DirSizeInfo) String() string {
+	return fmt.Sprintf("%s in %d files and %d directories", ConvertBytes(uint64(s.Bytes)), s.Files, s.Dirs)
+}
+
+// fileKey identifies a file on a device, to count hard links once.
+type fileKey struct {
+	dev, ino uint64
+}
+
+// DirSize returns the total size of the files under path, recursively.
+//
+// Files with several hard links are counted once on platforms exposing file identities.
+// Unreadable entries are reported in the returned error, along with the size of the
+// readable ones.
+//
+// Example:
+//
+//	size, err := DirSize("/var/lib/app", WithDirSizeConcurrency(8))
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(size) // 1.50 GB in 1200 files and 35 directories
+func DirSize(path string, opts ...DirSizeOption) (DirSizeInfo, error) {
+	o := dirSizeOptions{symlinks: SymlinkCopy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return DirSizeInfo{}, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	s := &dirSizer{
+		o:       o,
+		sem:     make(chan struct{}, max(o.concurrency, 1)-1),
+		links:   make(map[fileKey]bool),
+		visited: make(map[string]bool),
+	}
+	if !info.IsDir() {
+		s.addFile(info)
+	} else {
+		s.walk(path)
+		s.wg.Wait()
+	}
+	return DirSizeInfo{
+		Bytes:    s.bytes.Load(),
+		Files:    s.files.Load(),
+		Dirs:     s.dirs.Load(),
+		Symlinks: s.symlinks.Load(),
+	}, errors.Join(s.errs...)
+}
+
+// dirSizer holds the state of a DirSize walk.
+type dirSizer struct {
+	o        dirSizeOptions
+	sem      chan struct{}
+	wg       sync.WaitGroup
+	bytes    atomic.Int64
+	files    atomic.Int64
+	dirs     atomic.Int64
+	symlinks atomic.Int64
+	mu       sync.Mutex
+	errs     []error
+	links    map[fileKey]bool
+	visited  map[string]bool
+}
+
+// walk counts the content of the directory dir, reading subdirectories in parallel
+// while workers are available.
+func (s *dirSizer) walk(dir string) {
+	if s.o.symlinks == SymlinkFollow && !s.visit(dir) {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.fail(fmt.Errorf("failed to read directory %s: %w", dir, err))
+	}
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				s.fail(err)
+			}
+			continue
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			switch s.o.symlinks {
+			case SymlinkSkip:
+				continue
+			case SymlinkCopy:
+				s.symlinks.Add(1)
+				s.bytes.Add(info.Size())
+				continue
+			default:
+				if info, err = os.Stat(entryPath); err != nil {
+					s.fail(fmt.Errorf("failed to follow symbolic link %s: %w", entryPath, err))
+					continue
+				}
+				s.symlinks.Add(1)
+			}
+		}
+		if !info.IsDir() {
+			s.addFile(info)
+			continue
+		}
+		s.dirs.Add(1)
+		select {
+		case s.sem <- struct{}{}:
+			s.wg.Add(1)
+			go func(dir string) {
+				defer s.wg.Done()
+				s.walk(dir)
+				<-s.sem
+			}(entryPath)
+		default:
+			s.walk(entryPath)
+		}
+	}
+}
+
+// visit reports whether the directory was not traversed yet, through another link.
+func (s *dirSizer) visit(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.visited[real] {
+		return false
+	}
+	s.visited[real] = true
+	return true
+}
+
+// addFile counts the file described by info, unless it is a hard link already counted.
+func (s *dirSizer) addFile(info fs.FileInfo) {
+	if id, ok := hardLinkID(info); ok {
+		s.mu.Lock()
+		seen := s.links[id]
+		s.links[id] = true
+		s.mu.Unlock()
+		if seen {
+			return
+		}
+	}
+	s.files.Add(1)
+	s.bytes.Add(info.Size())
+}
+
+// fail records an error.
+func (s *dirSizer) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+// DiskUsageInfo describes the usage of a filesystem, as returned by DiskUsage.
+type DiskUsageInfo struct {
+	// Total, Free and Available are in bytes. Available is the space usable by
+	// unprivileged users, which excludes the blocks reserved for the superuser.
+	Total     uint64
+	Free      uint64
+	Available uint64
+	// Inodes and FreeInodes are zero on filesystems without inodes, such as on Windows.
+	Inodes     uint64
+	FreeInodes uint64
+}
+
+// Used returns the number of bytes in use.
+func (u DiskUsageInfo) Used() uint64 {
+	return u.Total - u.Free
+}
+
+// AvailablePercent returns the available space as a percentage of the total.
+func (u DiskUsageInfo) AvailablePercent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Available) / float64(u.Total) * 100
+}
+
+// String returns the usage formatted with ConvertBytes.
+func (u DiskUsageInfo) String() string {
+	return fmt.Sprintf("%s available of %s (%.1f%%)", ConvertBytes(u.Available), ConvertBytes(u.Total), u.AvailablePercent())
+}
+
+// DiskUsage returns the usage of the filesystem containing path.
+// It returns an error wrapping errors.ErrUnsupported on platforms without statfs.
+//
+// Example:
+//
+//	usage, err := DiskUsage("/var/lib/app")
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(usage) // 12.00 GB available of 100.00 GB (12.0%)
+func DiskUsage(path string) (DiskUsageInfo, error) {
+	usage, err := diskUsage(path)
+	if err != nil {
+		return DiskUsageInfo{}, fmt.Errorf("failed to read disk usage of %s: %w", path, err)
+	}
+	return usage, nil
+}
+
+// CheckDiskSpace returns the usage of the filesystem containing path, with an error
+// wrapping ErrLowDiskSpace if the available space is below minFree, a size in the
+// ConvertToBytes syntax, or below minFreePercent of the total. An empty minFree or
+// zero minFreePercent disables the corresponding check.
+//
+// Example:
+//
+//	// Warn when free < 5GiB or < 10%.
+//	if _, err := CheckDiskSpace("/var/lib/app", "5GiB", 10); errors.Is(err, ErrLowDiskSpace) {
+//		log.Printf("Warning: %v", err)
+//	}
+func CheckDiskSpace(path, minFree string, minFreePercent float64) (DiskUsageInfo, error) {
+	var threshold int64
+	if minFree != "" {
+		var err error
+		if threshold, err = ConvertToBytes(minFree); err != nil {
+			return DiskUsageInfo{}, fmt.Errorf("invalid minimum free space %q: %w", minFree, err)
+		}
+	}
+	usage, err := DiskUsage(path)
+	if err != nil {
+		return usage, err
+	}
+	if minFree != "" && usage.Available < uint64(max(threshold, 0)) {
+		return usage, fmt.Errorf("%w on %s: %s available, below %s", ErrLowDiskSpace, path,
+			ConvertBytes(usage.Available), ConvertBytes(uint64(threshold)))
+	}
+	if minFreePercent > 0 && usage.AvailablePercent() < minFreePercent {
+		return usage, fmt.Errorf("%w on %s: %.1f%% available, below %.1f%%", ErrLowDiskSpace, path,
+			usage.AvailablePercent(), minFreePercent)
+	}
+	return usage, nil
+}
@@ -0,0 +1,120 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{
+		"a.txt":       "12345",
+		"sub/b.txt":   "1234567890",
+		"sub/deep/c":  "123",
+		"other/d.txt": "1",
+	})
+	for _, n := range []int{1, 4} {
+		size, err := DirSize(dir, WithDirSizeConcurrency(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DirSizeInfo{Bytes: 19, Files: 4, Dirs: 3}
+		if size != want {
+			t.Errorf("concurrency %d: expected %+v, got %+v", n, want, size)
+		}
+	}
+	size, _ := DirSize(filepath.Join(dir, "a.txt"))
+	if size.Bytes != 5 || size.Files != 1 {
+		t.Errorf("unexpected size of a file: %+v", size)
+	}
+	if _, err := DirSize(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestDirSizeHardLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not de-duplicated on Windows")
+	}
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{"data.bin": "1234567890"})
+	if err := os.Link(filepath.Join(dir, "data.bin"), filepath.Join(dir, "link.bin")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	size, err := DirSize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Bytes != 10 || size.Files != 1 {
+		t.Errorf("expected hard links to be counted once, got %+v", size)
+	}
+}
+
+func TestDirSizeSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{"target/file.txt": "12345"})
+	if err := os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+	// A cycle, which must not be followed forever.
+	if err := os.Symlink("..", filepath.Join(dir, "target", "parent")); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := DirSize(dir, WithDirSizeSymlinks(SymlinkSkip))
+	if err != nil || size.Bytes != 5 || size.Symlinks != 0 {
+		t.Errorf("SymlinkSkip: unexpected %+v, %v", size, err)
+	}
+	size, err = DirSize(dir)
+	if err != nil || size.Bytes != 5+int64(len("target"))+int64(len("..")) || size.Symlinks != 2 {
+		t.Errorf("SymlinkCopy: unexpected %+v, %v", size, err)
+	}
+	size, err = DirSize(dir, WithDirSizeSymlinks(SymlinkFollow))
+	if err != nil || size.Bytes != 5 || size.Files != 1 {
+		t.Errorf("SymlinkFollow: unexpected %+v, %v", size, err)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	usage, err := DiskUsage(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("disk usage not supported on this platform")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Total == 0 || usage.Free > usage.Total || usage.Available > usage.Total {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if !strings.Contains(usage.String(), "available of") {
+		t.Errorf("unexpected string %q", usage.String())
+	}
+
+	if _, err := CheckDiskSpace(dir, "", 0); err != nil {
+		t.Errorf("expected no error without thresholds, got %v", err)
+	}
+	if _, err := CheckDiskSpace(dir, "1EiB", 0); !errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("expected ErrLowDiskSpace, got %v", err)
+	}
+	if _, err := CheckDiskSpace(dir, "", 100.1); !errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("expected ErrLowDiskSpace, got %v", err)
+	}
+	if _, err := CheckDiskSpace(dir, "lots", 0); err == nil || errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("expected an invalid size error, got %v", err)
+	}
+	if _, err := DiskUsage(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
+
+func TestDirSizeInfoString(t *testing.T) {
+	s := DirSizeInfo{Bytes: 3 * 1024 * 1024, Files: 2, Dirs: 1}.String()
+	if s != "3.00 MB in 2 files and 1 directories" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
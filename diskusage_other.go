@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package goutils
+
+import "errors"
+
+// diskUsage is not supported on this platform.
+func diskUsage(string) (DiskUsageInfo, error) {
+	return DiskUsageInfo{}, errors.ErrUnsupported
+}
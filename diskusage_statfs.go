@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package goutils
+
+import "syscall"
+
+// diskUsage returns the usage of the filesystem containing path with statfs.
+func diskUsage(path string) (DiskUsageInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskUsageInfo{}, err
+	}
+	bsize := uint64(st.Bsize)
+	// Some fields are signed on FreeBSD, where the available blocks are negative
+	// once the reserved blocks are in use.
+	return DiskUsageInfo{
+		Total:      uint64(st.Blocks) * bsize,
+		Free:       uint64(st.Bfree) * bsize,
+		Available:  uint64(max(int64(st.Bavail), 0)) * bsize,
+		Inodes:     uint64(st.Files),
+		FreeInodes: uint64(max(int64(st.Ffree), 0)),
+	}, nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package goutils
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = kernel32.NewProc("GetDiskFreeSpaceExW")
+
+// diskUsage returns the usage of the volume containing path with GetDiskFreeSpaceExW.
+func diskUsage(path string) (DiskUsageInfo, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsageInfo{}, err
+	}
+	var available, total, free uint64
+	r, _, err := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&free)),
+	)
+	if r == 0 {
+		return DiskUsageInfo{}, err
+	}
+	return DiskUsageInfo{Total: total, Free: free, Available: available}, nil
+}
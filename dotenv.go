@@ -0,0 +1,305 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultDotenvFile is the file loaded when no path is given.
+const defaultDotenvFile = ".env"
+
+// LoadDotenv loads the variables of dotenv files into the process environment, without
+// overriding the variables already set. Earlier files take precedence over later ones.
+// Without paths, it loads ".env".
+//
+// Example:
+//
+//	if err := LoadDotenv(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+//		log.Fatal(err)
+//	}
+func LoadDotenv(paths ...string) error {
+	return loadDotenv(paths, false)
+}
+
+// OverloadDotenv is like LoadDotenv, but overrides the variables already set, and later
+// files take precedence over earlier ones.
+func OverloadDotenv(paths ...string) error {
+	return loadDotenv(paths, true)
+}
+
+// loadDotenv sets the variables of the files in the process environment.
+func loadDotenv(paths []string, override bool) error {
+	if len(paths) == 0 {
+		paths = []string{defaultDotenvFile}
+	}
+	for _, path := range paths {
+		values, err := readDotenvFile(path, nil)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			if _, exists := os.LookupEnv(key); exists && !override {
+				continue
+			}
+			if err := os.Setenv(key, value); err != nil {
+				return fmt.Errorf("failed to set %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadDotenv returns the variables of dotenv files without changing the process environment.
+// Later files take precedence over earlier ones. Without paths, it reads ".env".
+func ReadDotenv(paths ...string) (map[string]string, error) {
+	if len(paths) == 0 {
+		paths = []string{defaultDotenvFile}
+	}
+	values := make(map[string]string)
+	for _, path := range paths {
+		fileValues, err := readDotenvFile(path, values)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// readDotenvFile parses a dotenv file, resolving references to the variables of previous
+// files, then of the process environment.
+func readDotenvFile(path string, previous map[string]string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dotenv file: %w", err)
+	}
+	values, err := parseDotenv(data, true, previous)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dotenv file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// ParseDotenv parses dotenv content.
+//
+// The syntax is:
+//   - KEY=value lines, optionally prefixed with "export ". Blank lines and lines
+//     starting with "#" are ignored, as is " # comment" after unquoted values.
+//   - Double-quoted values support the escapes \n, \r, \t, \", \\ and \$.
+//   - Single-quoted values are literal; backtick-quoted values may contain both kinds of quotes.
+//     Quoted values may span several lines.
+//   - ${VAR} and {VAR} references are replaced like ReplaceEnvVars does, with the variables
+//     defined earlier in the content or else in the process environment, except in
+//     single-quoted values and "\$" escapes. Unknown references are kept as is.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseDotenv(data, true, nil)
+}
+
+// parseDotenv parses dotenv content, interpolating the references if interpolate is set,
+// with the variables of previous, of the content and of the process environment.
+func parseDotenv(data []byte, interpolate bool, previous map[string]string) (map[string]string, error) {
+	p := &dotenvParser{s: string(data), line: 1, values: make(map[string]string)}
+	lookup := func(name string) (string, bool) {
+		if value, ok := p.values[name]; ok {
+			return value, true
+		}
+		if value, ok := previous[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+	if !interpolate {
+		lookup = nil
+	}
+	for {
+		key, value, err := p.next(lookup)
+		if errors.Is(err, io.EOF) {
+			return p.values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+		p.values[key] = value
+	}
+}
+
+// dotenvParser reads the assignments of dotenv content.
+type dotenvParser struct {
+	s      string
+	pos    int
+	line   int
+	values map[string]string
+}
+
+// escapedDollar and escapedBrace stand for "\$" and the "{" following it in double-quoted
+// values until references are replaced, so that neither ${VAR} nor {VAR} matches.
+const (
+	escapedDollar = "\x00"
+	escapedBrace  = "\x01"
+)
+
+// next returns the next assignment, or io.EOF at the end of the content.
+func (p *dotenvParser) next(lookup func(string) (string, bool)) (string, string, error) {
+	for {
+		p.skip(" \t\r")
+		if p.pos >= len(p.s) {
+			return "", "", io.EOF
+		}
+		switch p.s[p.pos] {
+		case '\n':
+			p.pos++
+			p.line++
+			continue
+		case '#':
+			p.skipLine()
+			continue
+		}
+		break
+	}
+
+	if rest := p.s[p.pos:]; strings.HasPrefix(rest, "export ") || strings.HasPrefix(rest, "export\t") {
+		p.pos += len("export")
+		p.skip(" \t")
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isDotenvKeyChar(p.s[p.pos], p.pos == start) {
+		p.pos++
+	}
+	key := p.s[start:p.pos]
+	if key == "" {
+		return "", "", fmt.Errorf("invalid variable name at %q", p.rest())
+	}
+	p.skip(" \t")
+	if p.pos >= len(p.s) || p.s[p.pos] != '=' {
+		return "", "", fmt.Errorf("expected = after %s", key)
+	}
+	p.pos++
+	p.skip(" \t")
+
+	var value string
+	interpolate := lookup != nil
+	quote := byte(0)
+	if p.pos < len(p.s) {
+		quote = p.s[p.pos]
+	}
+	switch quote {
+	case '"', '\'', '`':
+		var err error
+		if value, err = p.quoted(quote); err != nil {
+			return "", "", err
+		}
+		interpolate = interpolate && quote != '\''
+		// Only a comment may follow the closing quote.
+		p.skip(" \t\r")
+		if p.pos < len(p.s) && p.s[p.pos] != '\n' && p.s[p.pos] != '#' {
+			return "", "", fmt.Errorf("unexpected %q after the value of %s", p.rest(), key)
+		}
+		p.skipLine()
+	default:
+		end := strings.IndexByte(p.s[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.s) - p.pos
+		}
+		value = p.s[p.pos : p.pos+end]
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		} else if i := strings.Index(value, "\t#"); i >= 0 {
+			value = value[:i]
+		}
+		value = strings.TrimSpace(value)
+		p.pos += end
+	}
+
+	if interpolate {
+		value = replaceEnvVariables(value, lookup)
+	}
+	return key, strings.NewReplacer(escapedDollar, "$", escapedBrace, "{").Replace(value), nil
+}
+
+// quoted reads a quoted value, which may span several lines.
+func (p *dotenvParser) quoted(quote byte) (string, error) {
+	startLine := p.line
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\n':
+			p.line++
+		case c == '\\' && quote == '"' && p.pos < len(p.s):
+			escaped := p.s[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '$':
+				b.WriteString(escapedDollar)
+				if p.pos < len(p.s) && p.s[p.pos] == '{' {
+					b.WriteString(escapedBrace)
+					p.pos++
+				}
+			case '"', '\\':
+				b.WriteByte(escaped)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(escaped)
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	p.line = startLine
+	return "", fmt.Errorf("unterminated %c quote", quote)
+}
+
+// skip advances past the characters in chars.
+func (p *dotenvParser) skip(chars string) {
+	for p.pos < len(p.s) && strings.IndexByte(chars, p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// skipLine advances to the end of the line.
+func (p *dotenvParser) skipLine() {
+	if i := strings.IndexByte(p.s[p.pos:], '\n'); i >= 0 {
+		p.pos += i
+		return
+	}
+	p.pos = len(p.s)
+}
+
+// rest returns the rest of the current line, for error messages.
+func (p *dotenvParser) rest() string {
+	rest := p.s[p.pos:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+// isDotenvKeyChar reports whether c may appear in a variable name.
+func isDotenvKeyChar(c byte, first bool) bool {
+	switch {
+	case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		return true
+	case '0' <= c && c <= '9', c == '.', c == '-':
+		return !first
+	}
+	return false
+}
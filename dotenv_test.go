@@ -0,0 +1,117 @@
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("TEST_DOTENV_HOME", "/home/app")
+	content := `# comment
+export NAME=app
+PORT = 8080 # inline comment
+URL=http://host/#anchor
+EMPTY=
+DATA_DIR=${TEST_DOTENV_HOME}/data
+LOG_DIR="{DATA_DIR}/logs"
+GREETING="hello\n\"world\" \$HOME \\"
+ESCAPED="\${NAME} \$NAME {NAME}"
+LITERAL='${NAME} \n'
+RAW=` + "`it's \"quoted\" ${NAME}`" + `
+CERT="-----BEGIN-----
+line
+-----END-----"  # trailing comment
+UNKNOWN=${TEST_DOTENV_UNSET}
+`
+	values, err := ParseDotenv(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"NAME":     "app",
+		"PORT":     "8080",
+		"URL":      "http://host/#anchor",
+		"EMPTY":    "",
+		"DATA_DIR": "/home/app/data",
+		"LOG_DIR":  "/home/app/data/logs",
+		"GREETING": "hello\n\"world\" $HOME \\",
+		"LITERAL":  `${NAME} \n`,
+		"ESCAPED":  "${NAME} $NAME app",
+		"RAW":      `it's "quoted" app`,
+		"CERT":     "-----BEGIN-----\nline\n-----END-----",
+		"UNKNOWN":  "${TEST_DOTENV_UNSET}",
+	}
+	if len(values) != len(want) {
+		t.Errorf("expected %d values, got %v", len(want), values)
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	for content, line := range map[string]string{
+		"A=1\n=2\n":                 "line 2",
+		"A=1\nB\n":                  "line 2",
+		"A=1\nB=\"open\nC=3\n":      "line 2",
+		"A='value' extra\n":         "line 1",
+		"A=1\n\n1NUMBER=value\n":    "line 3",
+		"A=`multi\nline` B\n":       "line 2",
+		"export\nA=1\n":             "line 1",
+		"A=\"ok\"\nB='unterminated": "line 2",
+	} {
+		_, err := ParseDotenv(strings.NewReader(content))
+		if err == nil || !strings.Contains(err.Error(), line) {
+			t.Errorf("%q: expected an error on %s, got %v", content, line, err)
+		}
+	}
+}
+
+func TestLoadDotenv(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestFile(t, dir, ".env", []byte("TEST_DOTENV_A=first\nTEST_DOTENV_B=first\n"))
+	second := writeTestFile(t, dir, ".env.local", []byte("TEST_DOTENV_B=second\nTEST_DOTENV_C=${TEST_DOTENV_A}-c\n"))
+	t.Setenv("TEST_DOTENV_A", "process")
+	t.Setenv("TEST_DOTENV_B", "")
+	t.Setenv("TEST_DOTENV_C", "")
+	for _, name := range []string{"TEST_DOTENV_B", "TEST_DOTENV_C"} {
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := ReadDotenv(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["TEST_DOTENV_A"] != "first" || values["TEST_DOTENV_B"] != "second" || values["TEST_DOTENV_C"] != "first-c" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if _, ok := os.LookupEnv("TEST_DOTENV_B"); ok {
+		t.Error("expected ReadDotenv not to change the environment")
+	}
+
+	if err := LoadDotenv(first, second); err != nil {
+		t.Fatal(err)
+	}
+	if a, b, c := os.Getenv("TEST_DOTENV_A"), os.Getenv("TEST_DOTENV_B"), os.Getenv("TEST_DOTENV_C"); a != "process" || b != "first" || c != "process-c" {
+		t.Errorf("unexpected environment after LoadDotenv: %s %s %s", a, b, c)
+	}
+
+	if err := OverloadDotenv(first, second); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := os.Getenv("TEST_DOTENV_A"), os.Getenv("TEST_DOTENV_B"); a != "first" || b != "second" {
+		t.Errorf("unexpected environment after OverloadDotenv: %s %s", a, b)
+	}
+
+	if err := LoadDotenv(filepath.Join(dir, "missing.env")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
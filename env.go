@@ -0,0 +1,391 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// strictEnv makes the getters without an error result panic on malformed values.
+var strictEnv atomic.Bool
+
+// SetEnvStrict enables or disables the strict mode of the environment getters.
+//
+// By default, getters such as EnvInt and EnvBool silently return their default value when
+// a variable is malformed. In strict mode, they panic with the error instead, usually an
+// *EnvError, so that a typo such as PORT=80a stops the program at startup rather than running
+// on the default. Setting both NAME and NAME_FILE panics with an error wrapping ErrEnvConflict.
+// The getters with an error result, such as EnvIntE, are not affected.
+func SetEnvStrict(strict bool) {
+	strictEnv.Store(strict)
+}
+
+// EnvError reports an environment variable whose value does not have the expected format.
+type EnvError struct {
+	Name     string // Name of the variable
+	Value    string // Raw value of the variable
+	Expected string // Description of the expected format
+	Err      error  // Underlying parsing error, if any
+}
+
+// Error returns the name, raw value and expected format of the variable.
+func (e *EnvError) Error() string {
+	return fmt.Sprintf("invalid value %q for environment variable %s: expected %s", e.Value, e.Name, e.Expected)
+}
+
+// Unwrap returns the underlying parsing error.
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// ErrEnvConflict is returned by ResolveEnv when both a variable and its _FILE variant are set.
+var ErrEnvConflict = errors.New("environment variable and its _FILE variant are both set")
+
+// defaultEnvFileMaxSize is the default size limit of the files read by ResolveEnv.
+const defaultEnvFileMaxSize = 1 << 20
+
+// envFileMaxSize is the size limit of the files read by ResolveEnv, or 0 for the default.
+var envFileMaxSize atomic.Int64
+
+// SetEnvFileMaxSize sets the size limit of the files referenced by <NAME>_FILE variables.
+// A size of 0 or less restores the default of 1MiB.
+func SetEnvFileMaxSize(size int64) {
+	envFileMaxSize.Store(max(size, 0))
+}
+
+// ResolveEnv retrieves the value of the environment variable name, following the Docker
+// and Kubernetes secrets convention: when name is unset, and <name>_FILE is set, the value
+// is read from the file it references, without the trailing newline.
+//
+// It reports whether the variable was found, and returns an error if both name and
+// <name>_FILE are set (ErrEnvConflict), or if the file cannot be read or exceeds the size
+// limit (see SetEnvFileMaxSize). The getters with an error result, such as EnvIntE, and
+// LoadEnv use it. The getters without one, such as Env and EnvInt, panic with ErrEnvConflict
+// in strict mode (see SetEnvStrict); otherwise they prefer name, with a warning printed
+// once per variable.
+//
+// Example:
+//
+//	// DB_PASSWORD_FILE=/run/secrets/db
+//	password, ok, err := ResolveEnv("DB_PASSWORD")
+func ResolveEnv(name string) (string, bool, error) {
+	return ResolveEnvFrom(osEnv, name)
+}
+
+// ResolveEnvFrom is like ResolveEnv, but resolves the variables from env. The files are
+// still read from the operating system.
+func ResolveEnvFrom(env Environment, name string) (string, bool, error) {
+	value, exists := env.LookupEnv(name)
+	path, fileExists := env.LookupEnv(name + "_FILE")
+	switch {
+	case fileExists && path != "" && exists:
+		return "", false, fmt.Errorf("%w: %s and %s_FILE", ErrEnvConflict, name, name)
+	case exists:
+		return value, true, nil
+	case !fileExists || path == "":
+		return "", false, nil
+	}
+	data, err := readEnvFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// lenientEnvironment hides the <NAME>_FILE variables of the variables set in env, with a
+// warning printed once per name. The getters without an error result use it to keep
+// returning NAME when both are set. In strict mode, nothing is hidden, so the conflict
+// is reported as an error.
+type lenientEnvironment struct {
+	Environment
+}
+
+// lenientEnv is the process environment for the getters without an error result.
+var lenientEnv Environment = lenientEnvironment{osEnv}
+
+// envConflictWarned records the names whose conflict has already been reported.
+var envConflictWarned sync.Map
+
+func (l lenientEnvironment) LookupEnv(name string) (string, bool) {
+	value, ok := l.Environment.LookupEnv(name)
+	if base, isFile := strings.CutSuffix(name, "_FILE"); ok && isFile && value != "" && !strictEnv.Load() {
+		if _, set := l.Environment.LookupEnv(base); set {
+			if _, warned := envConflictWarned.LoadOrStore(base, true); !warned {
+				_, _ = fmt.Fprintf(defaultErrorWriter, "Warning: %v: %s and %s, using %s\n", ErrEnvConflict, base, name, base)
+			}
+			return "", false
+		}
+	}
+	return value, ok
+}
+
+// readEnvFile reads a file referenced by a <NAME>_FILE variable, up to the size limit.
+func readEnvFile(path string) ([]byte, error) {
+	limit := envFileMaxSize.Load()
+	if limit == 0 {
+		limit = defaultEnvFileMaxSize
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file %s exceeds the size limit of %d bytes", path, limit)
+	}
+	return data, nil
+}
+
+// parseEnv parses the variable name of env, returning defaultValue when it is unset or empty,
+// and an *EnvError when parse fails.
+func parseEnv[T any](env Environment, name string, defaultValue T, expected string, parse func(string) (T, error)) (T, error) {
+	value, _, err := ResolveEnvFrom(env, name)
+	if err != nil {
+		return defaultValue, err
+	}
+	if value == "" {
+		return defaultValue, nil
+	}
+	v, err := parse(value)
+	if err != nil {
+		return defaultValue, &EnvError{Name: name, Value: value, Expected: expected, Err: err}
+	}
+	return v, nil
+}
+
+// envValue returns v, or panics with err in strict mode.
+func envValue[T any](v T, err error) T {
+	if err != nil && strictEnv.Load() {
+		panic(err)
+	}
+	return v
+}
+
+// EnvIntE is like EnvInt, but returns an *EnvError when the value is not an integer.
+func EnvIntE(envName string, defaultValue int) (int, error) {
+	return envIntFrom(osEnv, envName, defaultValue)
+}
+
+// envIntFrom parses the integer variable envName of env.
+func envIntFrom(env Environment, envName string, defaultValue int) (int, error) {
+	return parseEnv(env, envName, defaultValue, "an integer", strconv.Atoi)
+}
+
+// EnvBoolE is like EnvBool, but returns an *EnvError when the value is not a boolean.
+func EnvBoolE(envName string, defaultValue bool) (bool, error) {
+	return envBoolFrom(osEnv, envName, defaultValue)
+}
+
+// envBoolFrom parses the boolean variable envName of env.
+func envBoolFrom(env Environment, envName string, defaultValue bool) (bool, error) {
+	return parseEnv(env, envName, defaultValue, "a boolean (true, false, 1, 0)", strconv.ParseBool)
+}
+
+// EnvInt64 retrieves the 64-bit integer value of the environment variable named by the key.
+func EnvInt64(envName string, defaultValue int64) int64 {
+	return envValue(envInt64From(lenientEnv, envName, defaultValue))
+}
+
+// EnvInt64E is like EnvInt64, but returns an *EnvError when the value is not an integer.
+func EnvInt64E(envName string, defaultValue int64) (int64, error) {
+	return envInt64From(osEnv, envName, defaultValue)
+}
+
+// envInt64From parses the variable envName of env for EnvInt64 and EnvInt64E.
+func envInt64From(env Environment, envName string, defaultValue int64) (int64, error) {
+	return parseEnv(env, envName, defaultValue, "an integer", func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+// EnvUint retrieves the unsigned integer value of the environment variable named by the key.
+func EnvUint(envName string, defaultValue uint) uint {
+	return envValue(envUintFrom(lenientEnv, envName, defaultValue))
+}
+
+// EnvUintE is like EnvUint, but returns an *EnvError when the value is not an unsigned integer.
+func EnvUintE(envName string, defaultValue uint) (uint, error) {
+	return envUintFrom(osEnv, envName, defaultValue)
+}
+
+// envUintFrom parses the variable envName of env for EnvUint and EnvUintE.
+func envUintFrom(env Environment, envName string, defaultValue uint) (uint, error) {
+	return parseEnv(env, envName, defaultValue, "a non-negative integer", func(s string) (uint, error) {
+		n, err := strconv.ParseUint(s, 10, strconv.IntSize)
+		return uint(n), err
+	})
+}
+
+// EnvFloat retrieves the floating-point value of the environment variable named by the key.
+func EnvFloat(envName string, defaultValue float64) float64 {
+	return envValue(envFloatFrom(lenientEnv, envName, defaultValue))
+}
+
+// EnvFloatE is like EnvFloat, but returns an *EnvError when the value is not a number.
+func EnvFloatE(envName string, defaultValue float64) (float64, error) {
+	return envFloatFrom(osEnv, envName, defaultValue)
+}
+
+// envFloatFrom parses the variable envName of env for EnvFloat and EnvFloatE.
+func envFloatFrom(env Environment, envName string, defaultValue float64) (float64, error) {
+	return parseEnv(env, envName, defaultValue, "a number", func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+// EnvDuration retrieves the duration value of the environment variable named by the key,
+// parsed with ParseDuration, such as "30s" or "7d".
+func EnvDuration(envName string, defaultValue time.Duration) time.Duration {
+	return envValue(envDurationFrom(lenientEnv, envName, defaultValue))
+}
+
+// EnvDurationE is like EnvDuration, but returns an *EnvError when the value is not a duration.
+func EnvDurationE(envName string, defaultValue time.Duration) (time.Duration, error) {
+	return envDurationFrom(osEnv, envName, defaultValue)
+}
+
+// envDurationFrom parses the variable envName of env for EnvDuration and EnvDurationE.
+func envDurationFrom(env Environment, envName string, defaultValue time.Duration) (time.Duration, error) {
+	return parseEnv(env, envName, defaultValue, "a duration such as 30s, 5m or 7d", ParseDuration)
+}
+
+// EnvBytes retrieves the byte size value of the environment variable named by the key,
+// parsed with ConvertToBytes, such as "10MiB". Plain numbers are bytes.
+//
+// Example:
+//
+//	maxBody := EnvBytes("MAX_BODY_SIZE", 10<<20)
+func EnvBytes(envName string, defaultValue int64) int64 {
+	return envValue(envBytesFrom(lenientEnv, envName, defaultValue))
+}
+
+// EnvBytesE is like EnvBytes, but returns an *EnvError when the value is not a byte size.
+func EnvBytesE(envName string, defaultValue int64) (int64, error) {
+	return envBytesFrom(osEnv, envName, defaultValue)
+}
+
+// envBytesFrom parses the variable envName of env for EnvBytes and EnvBytesE.
+func envBytesFrom(env Environment, envName string, defaultValue int64) (int64, error) {
+	return parseEnv(env, envName, defaultValue, "a byte size such as 512K or 10MiB", func(s string) (int64, error) {
+		return parseEnvInt(s, "bytes")
+	})
+}
+
+// EnvURL retrieves the absolute URL value of the environment variable named by the key.
+func EnvURL(envName string, defaultValue *url.URL) *url.URL {
+	return envValue(envURLFrom(lenientEnv, envName, defaultValue))
+}
+
+// EnvURLE is like EnvURL, but returns an *EnvError when the value is not an absolute URL.
+func EnvURLE(envName string, defaultValue *url.URL) (*url.URL, error) {
+	return envURLFrom(osEnv, envName, defaultValue)
+}
+
+// envURLFrom parses the variable envName of env for EnvURL and EnvURLE.
+func envURLFrom(env Environment, envName string, defaultValue *url.URL) (*url.URL, error) {
+	return parseEnv(env, envName, defaultValue, "an absolute URL such as https://example.com", func(s string) (*url.URL, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return nil, errors.New("missing scheme or host")
+		}
+		return u, nil
+	})
+}
+
+// EnvSlice retrieves the environment variable named by the key as a list split on sep
+// (default ","), trimming spaces and dropping empty items.
+//
+// Example:
+//
+//	origins := EnvSlice("ALLOWED_ORIGINS", ";", []string{"*"})
+func EnvSlice(envName, sep string, defaultValue []string) []string {
+	return envValue(envSliceFrom(lenientEnv, envName, sep, defaultValue))
+}
+
+// EnvSliceE is like EnvSlice, but returns the error when the variable cannot be resolved,
+// such as an unreadable <NAME>_FILE (see ResolveEnv).
+func EnvSliceE(envName, sep string, defaultValue []string) ([]string, error) {
+	return envSliceFrom(osEnv, envName, sep, defaultValue)
+}
+
+// envSliceFrom parses the variable envName of env for EnvSlice and EnvSliceE.
+func envSliceFrom(env Environment, envName, sep string, defaultValue []string) ([]string, error) {
+	if sep == "" {
+		sep = ","
+	}
+	return parseEnv(env, envName, defaultValue, fmt.Sprintf("a list separated by %q", sep), func(s string) ([]string, error) {
+		return splitEnvList(s, sep), nil
+	})
+}
+
+// EnvMap retrieves the environment variable named by the key as key=value pairs separated
+// by sep (default ","), such as "env=prod,team=core".
+func EnvMap(envName, sep string, defaultValue map[string]string) map[string]string {
+	return envValue(envMapFrom(lenientEnv, envName, sep, defaultValue))
+}
+
+// EnvMapE is like EnvMap, but returns an *EnvError when a pair has no "=" or an empty key.
+func EnvMapE(envName, sep string, defaultValue map[string]string) (map[string]string, error) {
+	return envMapFrom(osEnv, envName, sep, defaultValue)
+}
+
+// envMapFrom parses the variable envName of env for EnvMap and EnvMapE.
+func envMapFrom(env Environment, envName, sep string, defaultValue map[string]string) (map[string]string, error) {
+	if sep == "" {
+		sep = ","
+	}
+	return parseEnv(env, envName, defaultValue, fmt.Sprintf("key=value pairs separated by %q", sep), func(s string) (map[string]string, error) {
+		m := make(map[string]string)
+		for _, item := range splitEnvList(s, sep) {
+			key, value, ok := strings.Cut(item, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid pair %q", item)
+			}
+			m[key] = strings.TrimSpace(value)
+		}
+		return m, nil
+	})
+}
+
+// EnvEnum retrieves the value of the environment variable named by the key, which must be
+// one of allowed.
+//
+// Example:
+//
+//	level := EnvEnum("LOG_LEVEL", "info", "debug", "info", "warn", "error")
+func EnvEnum(envName, defaultValue string, allowed ...string) string {
+	return envValue(envEnumFrom(lenientEnv, envName, defaultValue, allowed...))
+}
+
+// EnvEnumE is like EnvEnum, but returns an *EnvError when the value is not one of allowed.
+func EnvEnumE(envName, defaultValue string, allowed ...string) (string, error) {
+	return envEnumFrom(osEnv, envName, defaultValue, allowed...)
+}
+
+// envEnumFrom parses the variable envName of env for EnvEnum and EnvEnumE.
+func envEnumFrom(env Environment, envName, defaultValue string, allowed ...string) (string, error) {
+	return parseEnv(env, envName, defaultValue, "one of "+strings.Join(allowed, ", "), func(s string) (string, error) {
+		if !slices.Contains(allowed, s) {
+			return "", fmt.Errorf("unknown value %q", s)
+		}
+		return s, nil
+	})
+}
@@ -0,0 +1,188 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedEnvGetters(t *testing.T) {
+	t.Setenv("TEST_ENV_INT64", "9000000000")
+	t.Setenv("TEST_ENV_UINT", "42")
+	t.Setenv("TEST_ENV_FLOAT", "0.25")
+	t.Setenv("TEST_ENV_DURATION", "1h30m")
+	t.Setenv("TEST_ENV_BYTES", "10MiB")
+	t.Setenv("TEST_ENV_URL", "https://example.com/api")
+	t.Setenv("TEST_ENV_SLICE", "a; b;;c ")
+	t.Setenv("TEST_ENV_MAP", "env=prod, team=core")
+	t.Setenv("TEST_ENV_ENUM", "debug")
+	t.Setenv("TEST_ENV_EMPTY", "")
+
+	if v := EnvInt64("TEST_ENV_INT64", 0); v != 9000000000 {
+		t.Errorf("EnvInt64: got %d", v)
+	}
+	if v := EnvUint("TEST_ENV_UINT", 0); v != 42 {
+		t.Errorf("EnvUint: got %d", v)
+	}
+	if v := EnvFloat("TEST_ENV_FLOAT", 0); v != 0.25 {
+		t.Errorf("EnvFloat: got %v", v)
+	}
+	if v := EnvDuration("TEST_ENV_DURATION", 0); v != 90*time.Minute {
+		t.Errorf("EnvDuration: got %v", v)
+	}
+	if v := EnvBytes("TEST_ENV_BYTES", 0); v != 10<<20 {
+		t.Errorf("EnvBytes: got %d", v)
+	}
+	if v := EnvURL("TEST_ENV_URL", nil); v == nil || v.Host != "example.com" {
+		t.Errorf("EnvURL: got %v", v)
+	}
+	if v := EnvSlice("TEST_ENV_SLICE", ";", nil); !equalStrings(v, []string{"a", "b", "c"}) {
+		t.Errorf("EnvSlice: got %q", v)
+	}
+	if v := EnvMap("TEST_ENV_MAP", "", nil); len(v) != 2 || v["env"] != "prod" || v["team"] != "core" {
+		t.Errorf("EnvMap: got %v", v)
+	}
+	if v := EnvEnum("TEST_ENV_ENUM", "info", "debug", "info"); v != "debug" {
+		t.Errorf("EnvEnum: got %s", v)
+	}
+	if v := EnvDuration("TEST_ENV_EMPTY", time.Second); v != time.Second {
+		t.Errorf("expected the default for an empty value, got %v", v)
+	}
+	if v := EnvSlice("TEST_ENV_UNSET", "", []string{"*"}); !equalStrings(v, []string{"*"}) {
+		t.Errorf("expected the default for an unset value, got %q", v)
+	}
+	if v, err := EnvSliceE("TEST_ENV_SLICE", ";", nil); err != nil || !equalStrings(v, []string{"a", "b", "c"}) {
+		t.Errorf("EnvSliceE: got %q, %v", v, err)
+	}
+	t.Setenv("TEST_ENV_HOSTS_FILE", filepath.Join(t.TempDir(), "missing"))
+	if v, err := EnvSliceE("TEST_ENV_HOSTS", "", []string{"*"}); err == nil || !equalStrings(v, []string{"*"}) {
+		t.Errorf("EnvSliceE: expected the default and an error for an unreadable file, got %q, %v", v, err)
+	}
+}
+
+func TestTypedEnvGettersErrors(t *testing.T) {
+	t.Setenv("TEST_ENV_PORT", "80a")
+
+	port, err := EnvIntE("TEST_ENV_PORT", 8080)
+	var envErr *EnvError
+	if !errors.As(err, &envErr) || port != 8080 {
+		t.Fatalf("expected an EnvError and the default, got %d, %v", port, err)
+	}
+	if envErr.Name != "TEST_ENV_PORT" || envErr.Value != "80a" || !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("unexpected error fields %+v", envErr)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "TEST_ENV_PORT") || !strings.Contains(msg, `"80a"`) || !strings.Contains(msg, "integer") {
+		t.Errorf("expected a descriptive message, got %q", msg)
+	}
+	if EnvInt("TEST_ENV_PORT", 8080) != 8080 {
+		t.Error("expected EnvInt to fall back to the default")
+	}
+
+	for name, get := range map[string]func() error{
+		"bool":     func() error { _, err := EnvBoolE("TEST_ENV_PORT", false); return err },
+		"uint":     func() error { _, err := EnvUintE("TEST_ENV_PORT", 0); return err },
+		"float":    func() error { _, err := EnvFloatE("TEST_ENV_PORT", 0); return err },
+		"duration": func() error { _, err := EnvDurationE("TEST_ENV_PORT", 0); return err },
+		"bytes":    func() error { _, err := EnvBytesE("TEST_ENV_PORT", 0); return err },
+		"url":      func() error { _, err := EnvURLE("TEST_ENV_PORT", nil); return err },
+		"map":      func() error { _, err := EnvMapE("TEST_ENV_PORT", "", nil); return err },
+		"enum":     func() error { _, err := EnvEnumE("TEST_ENV_PORT", "info", "debug", "info"); return err },
+	} {
+		if err := get(); !errors.As(err, &envErr) {
+			t.Errorf("%s: expected an EnvError, got %v", name, err)
+		}
+	}
+
+	SetEnvStrict(true)
+	defer SetEnvStrict(false)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected EnvInt to panic in strict mode")
+		} else if err, ok := r.(error); !ok || !errors.As(err, &envErr) {
+			t.Errorf("expected an EnvError panic, got %v", r)
+		}
+	}()
+	EnvInt("TEST_ENV_PORT", 8080)
+}
+
+func TestResolveEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeTestFile(t, dir, "db", []byte("s3cret\n"))
+	t.Setenv("TEST_ENV_DB_PASSWORD_FILE", secret)
+	t.Setenv("TEST_ENV_WORKERS_FILE", writeTestFile(t, dir, "workers", []byte("4\r\n")))
+	t.Setenv("TEST_ENV_DEBUG_FILE", writeTestFile(t, dir, "debug", []byte("true")))
+
+	if v := Env("TEST_ENV_DB_PASSWORD", ""); v != "s3cret" {
+		t.Errorf("Env: expected the file content, got %q", v)
+	}
+	if v := EnvInt("TEST_ENV_WORKERS", 1); v != 4 {
+		t.Errorf("EnvInt: expected 4, got %d", v)
+	}
+	if v := EnvBool("TEST_ENV_DEBUG", false); !v {
+		t.Error("EnvBool: expected true")
+	}
+
+	var cfg struct {
+		Password string `env:"DB_PASSWORD" required:"true"`
+	}
+	if err := LoadEnv(&cfg, WithEnvPrefix("TEST_ENV_")); err != nil || cfg.Password != "s3cret" {
+		t.Errorf("LoadEnv: expected the file content, got %q, %v", cfg.Password, err)
+	}
+
+	t.Setenv("TEST_ENV_DB_PASSWORD", "plain")
+	if _, _, err := ResolveEnv("TEST_ENV_DB_PASSWORD"); err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+	if _, err := EnvIntE("TEST_ENV_DB_PASSWORD", 0); !errors.Is(err, ErrEnvConflict) {
+		t.Errorf("expected EnvIntE to report the conflict, got %v", err)
+	}
+	var warnings strings.Builder
+	defaultErrorWriter = &warnings
+	defer func() { defaultErrorWriter = os.Stderr }()
+	if v := Env("TEST_ENV_DB_PASSWORD", "default"); v != "plain" {
+		t.Errorf("expected the variable to win on conflict, got %q", v)
+	}
+	t.Setenv("TEST_ENV_WORKERS", "8")
+	if v := EnvInt("TEST_ENV_WORKERS", 1); v != 8 {
+		t.Errorf("expected the variable to win on conflict, got %d", v)
+	}
+	if !strings.Contains(warnings.String(), "TEST_ENV_DB_PASSWORD_FILE") || !strings.Contains(warnings.String(), "TEST_ENV_WORKERS_FILE") {
+		t.Errorf("expected conflict warnings, got %q", warnings.String())
+	}
+	warned := warnings.Len()
+	Env("TEST_ENV_DB_PASSWORD", "default")
+	EnvInt("TEST_ENV_WORKERS", 1)
+	if warnings.Len() != warned {
+		t.Errorf("expected each conflict to be reported once, got %q", warnings.String())
+	}
+	func() {
+		SetEnvStrict(true)
+		defer SetEnvStrict(false)
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected Env to panic on conflict in strict mode")
+			} else if err, ok := r.(error); !ok || !errors.Is(err, ErrEnvConflict) {
+				t.Errorf("expected an ErrEnvConflict panic, got %v", r)
+			}
+		}()
+		Env("TEST_ENV_DB_PASSWORD", "default")
+	}()
+	if err := LoadEnv(&cfg, WithEnvPrefix("TEST_ENV_")); !errors.Is(err, ErrEnvConflict) {
+		t.Errorf("expected LoadEnv to report the conflict, got %v", err)
+	}
+
+	t.Setenv("TEST_ENV_TOKEN_FILE", writeTestFile(t, dir, "token", []byte(strings.Repeat("x", 32))))
+	SetEnvFileMaxSize(16)
+	defer SetEnvFileMaxSize(0)
+	if _, _, err := ResolveEnv("TEST_ENV_TOKEN"); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Errorf("expected a size limit error, got %v", err)
+	}
+	t.Setenv("TEST_ENV_MISSING_FILE", filepath.Join(dir, "missing"))
+	if _, ok, err := ResolveEnv("TEST_ENV_MISSING"); err == nil || ok {
+		t.Errorf("expected an error for a missing file, got %v, %v", ok, err)
+	}
+}
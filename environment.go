@@ -0,0 +1,163 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// Environment is a source of environment variables, so that the Env helpers can resolve
+// variables from something other than the process environment, such as a map in tests.
+type Environment interface {
+	// LookupEnv retrieves the value of the variable name, and reports whether it is set.
+	LookupEnv(name string) (string, bool)
+	// Setenv sets the value of the variable name.
+	Setenv(name, value string) error
+}
+
+// osEnvironment is the Environment of the process.
+type osEnvironment struct{}
+
+// osEnv is the default Environment of the Env helpers.
+var osEnv Environment = osEnvironment{}
+
+// NewOSEnvironment returns the Environment of the process, backed by os.LookupEnv and os.Setenv.
+func NewOSEnvironment() Environment {
+	return osEnv
+}
+
+func (osEnvironment) LookupEnv(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+func (osEnvironment) Setenv(name, value string) error {
+	return os.Setenv(name, value)
+}
+
+// MapEnvironment is an in-memory Environment, safe for concurrent use.
+type MapEnvironment struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewMapEnvironment returns an Environment holding a copy of values.
+//
+// Example:
+//
+//	env := NewMapEnvironment(map[string]string{"PORT": "9090"})
+//	port := EnvIntFrom(env, "PORT", 8080)
+func NewMapEnvironment(values map[string]string) *MapEnvironment {
+	m := &MapEnvironment{values: make(map[string]string, len(values))}
+	for name, value := range values {
+		m.values[name] = value
+	}
+	return m
+}
+
+// LookupEnv retrieves the value of the variable name, and reports whether it is set.
+func (m *MapEnvironment) LookupEnv(name string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.values[name]
+	return value, ok
+}
+
+// Setenv sets the value of the variable name.
+func (m *MapEnvironment) Setenv(name, value string) error {
+	if name == "" {
+		return errors.New("empty environment variable name")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] = value
+	return nil
+}
+
+// prefixedEnvironment prepends a prefix to the names of the variables of an Environment.
+type prefixedEnvironment struct {
+	env    Environment
+	prefix string
+}
+
+// NewPrefixedEnvironment returns an Environment scoped to the variables of env starting
+// with prefix, such as "APP_": looking up "PORT" returns the value of "APP_PORT".
+func NewPrefixedEnvironment(env Environment, prefix string) Environment {
+	return prefixedEnvironment{env: env, prefix: prefix}
+}
+
+func (p prefixedEnvironment) LookupEnv(name string) (string, bool) {
+	return p.env.LookupEnv(p.prefix + name)
+}
+
+func (p prefixedEnvironment) Setenv(name, value string) error {
+	return p.env.Setenv(p.prefix+name, value)
+}
+
+// layeredEnvironment looks variables up in several Environments in turn.
+type layeredEnvironment []Environment
+
+// NewLayeredEnvironment returns an Environment looking variables up in each of envs in
+// turn, so that the first ones override the next ones. Variables are set in the first one.
+//
+// Example:
+//
+//	// APP_PORT overrides PORT, which overrides the built-in defaults.
+//	env := NewLayeredEnvironment(
+//		NewPrefixedEnvironment(NewOSEnvironment(), "APP_"),
+//		NewOSEnvironment(),
+//		NewMapEnvironment(map[string]string{"PORT": "8080"}),
+//	)
+func NewLayeredEnvironment(envs ...Environment) Environment {
+	return layeredEnvironment(envs)
+}
+
+func (l layeredEnvironment) LookupEnv(name string) (string, bool) {
+	for _, env := range l {
+		if value, ok := env.LookupEnv(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func (l layeredEnvironment) Setenv(name, value string) error {
+	if len(l) == 0 {
+		return errors.New("no environment to set variables in")
+	}
+	return l[0].Setenv(name, value)
+}
+
+// EnvFrom is like Env, but resolves the variable from env.
+func EnvFrom(env Environment, envName string, defaultValue string) string {
+	value, exists, err := ResolveEnvFrom(lenientEnvironment{env}, envName)
+	if err != nil || !exists {
+		return envValue(defaultValue, err)
+	}
+	return value
+}
+
+// EnvIntFrom is like EnvInt, but resolves the variable from env.
+func EnvIntFrom(env Environment, envName string, defaultValue int) int {
+	return envValue(envIntFrom(lenientEnvironment{env}, envName, defaultValue))
+}
+
+// EnvBoolFrom is like EnvBool, but resolves the variable from env.
+func EnvBoolFrom(env Environment, envName string, defaultValue bool) bool {
+	return envValue(envBoolFrom(lenientEnvironment{env}, envName, defaultValue))
+}
+
+// SetEnvIn is like SetEnv, but sets the variable in env.
+func SetEnvIn(env Environment, name, value string) error {
+	if len(value) != 0 {
+		return env.Setenv(name, value)
+	}
+	return nil
+}
+
+// ReplaceEnvVarsFrom is like ReplaceEnvVars, but resolves the variables from env.
+func ReplaceEnvVarsFrom(env Environment, s string) string {
+	if s == "" {
+		return s
+	}
+	return replaceEnvVariables(replaceFunctions(s), env.LookupEnv)
+}
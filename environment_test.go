@@ -0,0 +1,77 @@
+package goutils
+
+import "testing"
+
+func TestEnvironments(t *testing.T) {
+	t.Parallel()
+	base := NewMapEnvironment(map[string]string{
+		"PORT":      "8080",
+		"APP_PORT":  "9090",
+		"APP_DEBUG": "true",
+		"HOST":      "localhost",
+	})
+	app := NewPrefixedEnvironment(base, "APP_")
+	env := NewLayeredEnvironment(app, base)
+
+	if v := EnvIntFrom(app, "PORT", 0); v != 9090 {
+		t.Errorf("prefixed: expected 9090, got %d", v)
+	}
+	if v := EnvFrom(app, "HOST", "none"); v != "none" {
+		t.Errorf("prefixed: expected the default, got %s", v)
+	}
+	if v := EnvFrom(env, "HOST", ""); v != "localhost" {
+		t.Errorf("layered: expected the fallback, got %s", v)
+	}
+	if !EnvBoolFrom(env, "DEBUG", false) || EnvIntFrom(env, "PORT", 0) != 9090 {
+		t.Error("layered: expected the prefixed variables to win")
+	}
+	if v := ReplaceEnvVarsFrom(env, "http://${HOST}:{PORT}/${MISSING}"); v != "http://localhost:9090/${MISSING}" {
+		t.Errorf("unexpected replacement %s", v)
+	}
+
+	if err := SetEnvIn(env, "NAME", "app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetEnvIn(env, "EMPTY", ""); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := base.LookupEnv("APP_NAME"); !ok || v != "app" {
+		t.Errorf("expected the variable to be set in the first layer, got %q", v)
+	}
+	if _, ok := base.LookupEnv("APP_EMPTY"); ok {
+		t.Error("expected empty values not to be set")
+	}
+	if err := NewLayeredEnvironment().Setenv("NAME", "app"); err == nil {
+		t.Error("expected an error without layers")
+	}
+}
+
+func TestEnvironmentLoadEnv(t *testing.T) {
+	t.Parallel()
+	secret := writeTestFile(t, t.TempDir(), "db", []byte("s3cret\n"))
+	env := NewMapEnvironment(map[string]string{
+		"APP_PORT":             "9090",
+		"APP_DB_PASSWORD_FILE": secret,
+	})
+
+	var cfg struct {
+		Port     int    `env:"PORT" default:"8080"`
+		Password string `env:"DB_PASSWORD" required:"true"`
+	}
+	if err := LoadEnv(&cfg, WithEnvironment(env), WithEnvPrefix("APP_")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9090 || cfg.Password != "s3cret" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if v := EnvFrom(NewPrefixedEnvironment(env, "APP_"), "DB_PASSWORD", ""); v != "s3cret" {
+		t.Errorf("expected the _FILE variable of the environment, got %q", v)
+	}
+
+	if err := env.Setenv("APP_DB_PASSWORD", "plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ResolveEnvFrom(env, "APP_DB_PASSWORD"); err == nil {
+		t.Error("expected a conflict error")
+	}
+}
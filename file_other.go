@@ -0,0 +1,34 @@
+//go:build !unix && !windows
+
+package goutils
+
+import (
+	"io/fs"
+	"os"
+)
+
+// dirSyncSupported reports whether directories can be flushed with fsync.
+const dirSyncSupported = false
+
+// reopenSignals is empty, as this platform has no SIGHUP.
+var reopenSignals []os.Signal
+
+// fileOwner is not supported on this platform.
+func fileOwner(fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// hardLinkID is not supported on this platform, so hard links are counted once per link.
+func hardLinkID(fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// chownFile is a no-op on this platform.
+func chownFile(*os.File, int, int) error {
+	return nil
+}
+
+// isCrossDevice is not supported on this platform.
+func isCrossDevice(error) bool {
+	return false
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// dirSyncSupported reports whether directories can be flushed with fsync.
+const dirSyncSupported = true
+
+// reopenSignals are the signals used by RotatingWriter.ReopenOnSignal by default.
+var reopenSignals = []os.Signal{syscall.SIGHUP}
+
+// fileOwner returns the user and group IDs of the file described by info.
+func fileOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// hardLinkID returns the identity of the file described by info if it has several hard links.
+func hardLinkID(info fs.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink <= 1 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// chownFile changes the owner of the open file.
+func chownFile(f *os.File, uid, gid int) error {
+	return f.Chown(uid, gid)
+}
+
+// isCrossDevice reports whether err is caused by a rename across filesystems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
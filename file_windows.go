@@ -0,0 +1,39 @@
+//go:build windows
+
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// errorNotSameDevice is the ERROR_NOT_SAME_DEVICE Windows error code.
+const errorNotSameDevice = syscall.Errno(17)
+
+// dirSyncSupported reports whether directories can be flushed with fsync.
+const dirSyncSupported = false
+
+// reopenSignals is empty, as this platform has no SIGHUP.
+var reopenSignals []os.Signal
+
+// fileOwner is not supported on this platform.
+func fileOwner(fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// hardLinkID is not supported on this platform, so hard links are counted once per link.
+func hardLinkID(fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// chownFile is a no-op on this platform.
+func chownFile(*os.File, int, int) error {
+	return nil
+}
+
+// isCrossDevice reports whether err is caused by a rename across volumes.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
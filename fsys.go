@@ -0,0 +1,495 @@
+package goutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WritableFS is a file system that can be modified, so file workflows can run against
+// the OS (OSFS) or in memory (MemFS) in unit tests.
+//
+// Names are slash-separated and unrooted, as for fs.FS.
+type WritableFS interface {
+	fs.FS
+	// Create creates or truncates the named file. New files get the permissions perm.
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	// MkdirAll creates the named directory and its missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// Chmod changes the permissions of the named file.
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// FileExistsFS is the fs.FS variant of FileExists.
+func FileExistsFS(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// FolderExistsFS is the fs.FS variant of FolderExists.
+func FolderExistsFS(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.IsDir()
+}
+
+// IsDirEmptyFS is the fs.FS variant of IsDirEmpty.
+func IsDirEmptyFS(fsys fs.FS, name string) (bool, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer func(f fs.File) {
+		_ = f.Close()
+	}(f)
+
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return false, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries, err := dir.ReadDir(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// CopyFileFS copies the file srcName of src to dstName in dst, for example from an embed.FS.
+func CopyFileFS(dst WritableFS, dstName string, src fs.FS, srcName string) (err error) {
+	sourceFile, err := src.Open(srcName)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func(sourceFile fs.File) {
+		_ = sourceFile.Close()
+	}(sourceFile)
+
+	info, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("source %s is a directory", srcName)
+	}
+	destinationFile, err := dst.Create(dstName, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer func(destinationFile io.WriteCloser) {
+		if closeErr := destinationFile.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close destination file: %w", closeErr)
+		}
+	}(destinationFile)
+
+	if _, err = io.Copy(destinationFile, sourceFile); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+// WriteToFileFS is the WritableFS variant of WriteToFile.
+func WriteToFileFS(fsys WritableFS, filePath, content string) error {
+	f, err := fsys.Create(filePath, defaultFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", filePath, err)
+	}
+	_, err = io.WriteString(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// ChangePermissionFS is the WritableFS variant of ChangePermission.
+func ChangePermissionFS(fsys WritableFS, filePath string, mod int) error {
+	return fsys.Chmod(filePath, fs.FileMode(mod))
+}
+
+// OSFS is a WritableFS for a directory of the OS file system. Like os.DirFS, it refuses
+// names with ".." components, but does not prevent symbolic links from escaping the directory.
+type OSFS struct {
+	fs.FS
+	dir string
+}
+
+// NewOSFS returns a WritableFS for the directory dir.
+func NewOSFS(dir string) *OSFS {
+	return &OSFS{FS: os.DirFS(dir), dir: dir}
+}
+
+// path returns the OS path of name.
+func (o *OSFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.dir, filepath.FromSlash(name)), nil
+}
+
+// Create creates or truncates the named file. New files get the permissions perm minus the umask.
+func (o *OSFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	p, err := o.path("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// MkdirAll creates the named directory and its missing parents, like os.MkdirAll.
+func (o *OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := o.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+// Remove removes the named file or empty directory.
+func (o *OSFS) Remove(name string) error {
+	p, err := o.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// Chmod changes the permissions of the named file.
+func (o *OSFS) Chmod(name string, mode fs.FileMode) error {
+	p, err := o.path("chmod", name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(p, mode)
+}
+
+// MemFS is an in-memory WritableFS, safe for concurrent use. Directories are created
+// implicitly for the files they contain, or explicitly with MkdirAll.
+//
+// Example:
+//
+//	fsys := NewMemFS()
+//	if err := WriteToFileFS(fsys, "config.json", "{}"); err != nil {
+//		t.Fatal(err)
+//	}
+//	if !FileExistsFS(fsys, "config.json") {
+//		t.Error("expected config.json")
+//	}
+type MemFS struct {
+	mu sync.RWMutex
+	// files maps the slash-separated names to the files and explicit directories.
+	files map[string]*memNode
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*memNode)}
+}
+
+// Open opens the named file. The content of an opened file does not change if the file is rewritten.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, err := m.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &memReader{Reader: bytes.NewReader(info.node.data), info: info}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for p, node := range m.files {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		child, _, nested := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		if nested {
+			// The child is a directory, explicit or implied by the nested file.
+			if explicit, ok := m.files[prefix+child]; ok {
+				node = explicit
+			} else {
+				node = implicitDir
+			}
+		}
+		seen[child] = true
+		entries = append(entries, &memInfo{name: child, node: node})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return &memDir{info: info, entries: entries}, nil
+}
+
+// stat returns the information of the named file. The caller must hold the lock.
+func (m *MemFS) stat(name string) (*memInfo, error) {
+	if node, ok := m.files[name]; ok {
+		return &memInfo{name: path.Base(name), node: node}, nil
+	}
+	// The root and the directories containing files exist implicitly.
+	if name == "." {
+		return &memInfo{name: ".", node: implicitDir}, nil
+	}
+	for p := range m.files {
+		if strings.HasPrefix(p, name+"/") {
+			return &memInfo{name: path.Base(name), node: implicitDir}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Create returns a writer for the named file, stored when it is closed. The parent directory
+// must exist. New files get the permissions perm; existing files keep theirs.
+func (m *MemFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, err := m.checkCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		perm = info.Mode().Perm()
+	}
+	return &memFile{fsys: m, name: name, mode: perm.Perm()}, nil
+}
+
+// checkCreate reports an error if the file name cannot be written, and returns its
+// information if it exists. The caller must hold the lock.
+func (m *MemFS) checkCreate(name string) (*memInfo, error) {
+	if parent := path.Dir(name); parent != "." {
+		if info, err := m.stat(parent); err != nil || !info.IsDir() {
+			return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	info, err := m.stat(name)
+	if err != nil {
+		return nil, nil
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("is a directory")}
+	}
+	return info, nil
+}
+
+// MkdirAll creates the named directory and its missing parents with the permissions perm.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name == "." {
+		return nil
+	}
+	p := ""
+	for _, part := range strings.Split(name, "/") {
+		p = path.Join(p, part)
+		info, err := m.stat(p)
+		if err != nil {
+			m.files[p] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+			continue
+		}
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: p, Err: errors.New("not a directory")}
+		}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (m *MemFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := m.stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		for p := range m.files {
+			if strings.HasPrefix(p, name+"/") {
+				return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+			}
+		}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Chmod changes the permissions of the named file. Opened files are not affected.
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := m.stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	// Files are replaced rather than modified, so opened files are not affected.
+	m.files[name] = &memNode{data: info.node.data, mode: info.Mode().Type() | mode.Perm(), modTime: info.ModTime()}
+	return nil
+}
+
+// memFile buffers the content written to a MemFS file until it is closed.
+type memFile struct {
+	fsys   *MemFS
+	name   string
+	mode   fs.FileMode
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, fs.ErrClosed
+	}
+	return f.buf.Write(p)
+}
+
+// Close stores the content in the file system. It fails if the parent directory was
+// removed or replaced since Create.
+func (f *memFile) Close() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	f.closed = true
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if _, err := f.fsys.checkCreate(f.name); err != nil {
+		return err
+	}
+	f.fsys.files[f.name] = &memNode{data: f.buf.Bytes(), mode: f.mode, modTime: time.Now()}
+	return nil
+}
+
+// memNode is a file or directory of a MemFS. Nodes are never modified once stored.
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// implicitDir is the node of the directories implied by the files they contain.
+var implicitDir = &memNode{mode: fs.ModeDir | 0555}
+
+// memInfo describes a MemFS node, as both an fs.FileInfo and an fs.DirEntry.
+type memInfo struct {
+	name string
+	node *memNode
+}
+
+func (i *memInfo) Name() string {
+	return i.name
+}
+
+func (i *memInfo) Size() int64 {
+	return int64(len(i.node.data))
+}
+
+func (i *memInfo) Mode() fs.FileMode {
+	return i.node.mode
+}
+
+func (i *memInfo) Type() fs.FileMode {
+	return i.node.mode.Type()
+}
+
+func (i *memInfo) ModTime() time.Time {
+	return i.node.modTime
+}
+
+func (i *memInfo) IsDir() bool {
+	return i.node.mode.IsDir()
+}
+
+func (i *memInfo) Sys() any {
+	return nil
+}
+
+func (i *memInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+func (i *memInfo) String() string {
+	return fs.FormatFileInfo(i)
+}
+
+// memReader is an opened MemFS file.
+type memReader struct {
+	*bytes.Reader
+	info *memInfo
+}
+
+func (r *memReader) Stat() (fs.FileInfo, error) {
+	return r.info, nil
+}
+
+func (r *memReader) Close() error {
+	return nil
+}
+
+// memDir is an opened MemFS directory, listing the entries read when it was opened.
+type memDir struct {
+	info    *memInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *memDir) Close() error {
+	return nil
+}
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
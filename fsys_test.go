@@ -0,0 +1,153 @@
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExistsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/app.json": {Data: []byte("{}")},
+		"empty":           {Mode: fs.ModeDir | 0755},
+	}
+	if !FileExistsFS(fsys, "config/app.json") || FileExistsFS(fsys, "config") || FileExistsFS(fsys, "missing") {
+		t.Error("FileExistsFS reported unexpected results")
+	}
+	if !FolderExistsFS(fsys, "config") || FolderExistsFS(fsys, "config/app.json") || FolderExistsFS(fsys, "missing") {
+		t.Error("FolderExistsFS reported unexpected results")
+	}
+
+	empty, err := IsDirEmptyFS(fsys, "empty")
+	if err != nil || !empty {
+		t.Errorf("IsDirEmptyFS(empty) = %v, %v, want true", empty, err)
+	}
+	empty, err = IsDirEmptyFS(fsys, "config")
+	if err != nil || empty {
+		t.Errorf("IsDirEmptyFS(config) = %v, %v, want false", empty, err)
+	}
+	if _, err := IsDirEmptyFS(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := NewMemFS()
+	if err := fsys.MkdirAll("data/cache", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteToFileFS(fsys, "data/state.json", `{"ok":true}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteToFileFS(fsys, "missing/state.json", "{}"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing parent, got %v", err)
+	}
+	if err := fstest.TestFS(fsys, "data/state.json", "data/cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "data/state.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != defaultFileMode {
+		t.Errorf("expected mode %v, got %v", defaultFileMode, info.Mode().Perm())
+	}
+	if err := ChangePermissionFS(fsys, "data/state.json", 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Rewriting a file keeps its permissions.
+	if err := WriteToFileFS(fsys, "data/state.json", `{"ok":false}`); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = fs.Stat(fsys, "data/state.json")
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("expected mode 0644, got %v", info.Mode().Perm())
+	}
+	if data, _ := fs.ReadFile(fsys, "data/state.json"); string(data) != `{"ok":false}` {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	if err := fsys.Remove("data"); err == nil {
+		t.Error("expected an error removing a non-empty directory")
+	}
+	if err := fsys.Remove("data/state.json"); err != nil {
+		t.Fatal(err)
+	}
+	if FileExistsFS(fsys, "data/state.json") {
+		t.Error("expected data/state.json to be removed")
+	}
+	if err := fsys.Remove("data/state.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.Create("../escape", 0644); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+
+	// A file whose parent is removed while it is written is not stored.
+	if err := fsys.MkdirAll("tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := fsys.Create("tmp/part", 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove("tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if FileExistsFS(fsys, "tmp/part") || FolderExistsFS(fsys, "tmp") {
+		t.Error("expected no orphan entry")
+	}
+}
+
+func TestCopyFileFS(t *testing.T) {
+	src := fstest.MapFS{
+		"templates/index.html": {Data: []byte("<html></html>"), Mode: 0444},
+	}
+	dst := NewMemFS()
+	if err := CopyFileFS(dst, "index.html", src, "templates/index.html"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile(dst, "index.html"); string(data) != "<html></html>" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if err := CopyFileFS(dst, "dir", src, "templates"); err == nil {
+		t.Error("expected an error copying a directory")
+	}
+	if err := CopyFileFS(dst, "other.html", src, "missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestOSFS(t *testing.T) {
+	dir := t.TempDir()
+	fsys := NewOSFS(dir)
+	if err := fsys.MkdirAll("a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteToFileFS(fsys, "a/b/file.txt", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a", "b", "file.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected content %q, %v", data, err)
+	}
+	if err := CopyFileFS(fsys, "copy.txt", fsys, "a/b/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if !FileExistsFS(fsys, "copy.txt") || !FolderExistsFS(fsys, "a/b") {
+		t.Error("expected copy.txt and a/b to exist")
+	}
+	if err := fsys.Remove("copy.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Create("../escape.txt", 0644); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+}
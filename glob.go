@@ -0,0 +1,396 @@
+package goutils
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GlobPattern is a compiled glob pattern matching slash-separated paths.
+//
+// The syntax extends path.Match:
+//   - "*" matches any sequence of characters except "/", and "?" a single one.
+//   - "[abc]", "[a-z]" and "[!a-z]" (or "[^a-z]") match a character class.
+//   - "{a,b}" matches any of the comma-separated alternatives, which may contain "/" and nest.
+//   - "**" as a whole path segment matches zero or more directories.
+//   - "\" escapes the next character.
+type GlobPattern struct {
+	pattern string
+	// alternatives holds the path segments of each brace expansion.
+	alternatives [][]string
+}
+
+// CompileGlob compiles a glob pattern, returning path.ErrBadPattern if it is malformed.
+func CompileGlob(pattern string) (*GlobPattern, error) {
+	clean := strings.TrimPrefix(strings.TrimPrefix(pattern, "./"), "/")
+	if clean == "" {
+		return nil, path.ErrBadPattern
+	}
+	expanded, err := expandBraces(clean)
+	if err != nil {
+		return nil, err
+	}
+	g := &GlobPattern{pattern: pattern}
+	for _, alt := range expanded {
+		var segs []string
+		for _, seg := range strings.Split(alt, "/") {
+			if seg == "**" {
+				// Consecutive "**" segments are equivalent to one.
+				if len(segs) > 0 && segs[len(segs)-1] == "**" {
+					continue
+				}
+			} else {
+				seg = translateClass(seg)
+				if _, err := path.Match(seg, ""); err != nil {
+					return nil, err
+				}
+			}
+			segs = append(segs, seg)
+		}
+		g.alternatives = append(g.alternatives, segs)
+	}
+	return g, nil
+}
+
+// String returns the pattern as given to CompileGlob.
+func (g *GlobPattern) String() string {
+	return g.pattern
+}
+
+// Match reports whether the slash-separated path name matches the pattern.
+func (g *GlobPattern) Match(name string) bool {
+	names := splitGlobPath(name)
+	for _, segs := range g.alternatives {
+		if matchSegments(segs, names) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether the slash-separated path name matches the glob pattern.
+// See GlobPattern for the syntax.
+//
+// Example:
+//
+//	ok, err := MatchGlob("configs/**/*.{json,yaml}", "configs/prod/eu/app.yaml") // true
+func MatchGlob(pattern, name string) (bool, error) {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	return g.Match(name), nil
+}
+
+// couldMatchBelow reports whether a path strictly below the directory dir could match.
+func (g *GlobPattern) couldMatchBelow(dir []string) bool {
+	for _, segs := range g.alternatives {
+		if prefixMatches(segs, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversBelow reports whether every path below the directory dir matches, as with "tmp/**".
+func (g *GlobPattern) coversBelow(dir []string) bool {
+	for _, segs := range g.alternatives {
+		n := len(segs)
+		if n == 0 || segs[n-1] != "**" {
+			continue
+		}
+		for k := 1; k <= len(dir); k++ {
+			if matchSegments(segs[:n-1], dir[:k]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GlobSet is an ordered list of glob patterns, where patterns starting with "!" exclude
+// the paths matched by the previous ones, such as "configs/**/*.json" and "!**/tmp/**".
+// The last pattern matching a path decides; a set starting with an exclusion
+// includes the paths matched by no pattern.
+type GlobSet struct {
+	patterns []*GlobPattern
+	negate   []bool
+}
+
+// NewGlobSet compiles the patterns of a GlobSet. Use "\!" for a pattern starting with a literal "!".
+func NewGlobSet(patterns ...string) (*GlobSet, error) {
+	s := &GlobSet{}
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		g, err := CompileGlob(strings.TrimPrefix(pattern, "!"))
+		if err != nil {
+			return nil, err
+		}
+		s.patterns = append(s.patterns, g)
+		s.negate = append(s.negate, negate)
+	}
+	return s, nil
+}
+
+// Match reports whether the slash-separated path name is selected by the set.
+func (s *GlobSet) Match(name string) bool {
+	matched := len(s.negate) > 0 && s.negate[0]
+	for i, g := range s.patterns {
+		if g.Match(name) {
+			matched = !s.negate[i]
+		}
+	}
+	return matched
+}
+
+// prune reports whether no path below the directory name can be selected.
+func (s *GlobSet) prune(name string) bool {
+	dir := splitGlobPath(name)
+	// Find the last pattern excluding or not selecting the whole subtree, and check
+	// whether a later pattern could select something below it.
+	for i := len(s.patterns) - 1; i >= 0; i-- {
+		if !s.negate[i] {
+			if s.patterns[i].couldMatchBelow(dir) {
+				return false
+			}
+			continue
+		}
+		if s.patterns[i].coversBelow(dir) {
+			return true
+		}
+	}
+	return len(s.negate) == 0 || !s.negate[0]
+}
+
+// WalkGlob walks the directory tree rooted at root like filepath.WalkDir, calling fn for the
+// entries whose path relative to root matches the patterns of a GlobSet. Directories that
+// cannot contain a match are not read. Symbolic links are not followed.
+//
+// Example:
+//
+//	err := WalkGlob("/etc/app", []string{"configs/**/*.json", "!**/tmp/**"},
+//		func(path string, d fs.DirEntry, err error) error {
+//			if err != nil {
+//				return err
+//			}
+//			return loadConfig(path)
+//		})
+func WalkGlob(root string, patterns []string, fn fs.WalkDirFunc) error {
+	return walkGlob(os.DirFS(root), patterns, func(name string, d fs.DirEntry, err error) error {
+		return fn(filepath.Join(root, filepath.FromSlash(name)), d, err)
+	})
+}
+
+// WalkGlobFS is the fs.FS variant of WalkGlob, calling fn with slash-separated paths.
+func WalkGlobFS(fsys fs.FS, patterns []string, fn fs.WalkDirFunc) error {
+	return walkGlob(fsys, patterns, fn)
+}
+
+// walkGlob walks fsys, calling fn for the matching entries.
+func walkGlob(fsys fs.FS, patterns []string, fn fs.WalkDirFunc) error {
+	set, err := NewGlobSet(patterns...)
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(name, d, err)
+		}
+		if name == "." {
+			return nil
+		}
+		if set.Match(name) {
+			if err := fn(name, d, nil); err != nil {
+				return err
+			}
+		}
+		if d.IsDir() && set.prune(name) {
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// Glob returns the paths under root matching the patterns of a GlobSet, sorted.
+// Unlike filepath.Glob, it supports "**", braces and exclusions, see GlobPattern.
+//
+// Example:
+//
+//	files, err := Glob("/var/lib/app", "**/*.db", "!cache/**")
+func Glob(root string, patterns ...string) ([]string, error) {
+	var matches []string
+	err := WalkGlob(root, patterns, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		matches = append(matches, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// GlobFS is the fs.FS variant of Glob, returning slash-separated paths.
+func GlobFS(fsys fs.FS, patterns ...string) ([]string, error) {
+	var matches []string
+	err := WalkGlobFS(fsys, patterns, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		matches = append(matches, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// splitGlobPath splits a slash-separated path into its non-empty segments.
+func splitGlobPath(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/'
+	})
+}
+
+// matchSegments reports whether the path segments match the pattern segments.
+func matchSegments(segs, names []string) bool {
+	for len(segs) > 0 {
+		if segs[0] == "**" {
+			rest := segs[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := range len(names) + 1 {
+				if matchSegments(rest, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segs[0], names[0]); !ok {
+			return false
+		}
+		segs, names = segs[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// prefixMatches reports whether the directory segments match a prefix of the pattern
+// segments, leaving segments to match below the directory.
+func prefixMatches(segs, dir []string) bool {
+	for len(dir) > 0 {
+		if len(segs) == 0 {
+			return false
+		}
+		if segs[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(segs[0], dir[0]); !ok {
+			return false
+		}
+		segs, dir = segs[1:], dir[1:]
+	}
+	return len(segs) > 0
+}
+
+// translateClass rewrites the "[!...]" negated classes of a segment to the "[^...]" of path.Match.
+func translateClass(seg string) string {
+	if !strings.Contains(seg, "[!") {
+		return seg
+	}
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		b.WriteByte(seg[i])
+		switch seg[i] {
+		case '\\':
+			if i+1 < len(seg) {
+				i++
+				b.WriteByte(seg[i])
+			}
+		case '[':
+			if i+1 < len(seg) && seg[i+1] == '!' {
+				b.WriteByte('^')
+				i++
+			}
+		}
+	}
+	return b.String()
+}
+
+// expandBraces expands the "{a,b}" alternatives of a pattern.
+func expandBraces(pattern string) ([]string, error) {
+	start, end, depth := -1, -1, 0
+	inClass := false
+scan:
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				end = i
+				break scan
+			}
+		}
+	}
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+	if end < 0 {
+		return nil, path.ErrBadPattern
+	}
+
+	var expanded []string
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	for _, alt := range splitAlternatives(pattern[start+1 : end]) {
+		more, err := expandBraces(prefix + alt + suffix)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, more...)
+	}
+	return expanded, nil
+}
+
+// splitAlternatives splits the content of braces on its top-level commas.
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alts, s[last:])
+}
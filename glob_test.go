@@ -0,0 +1,146 @@
+package goutils
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.json", "app.json", true},
+		{"*.json", "configs/app.json", false},
+		{"configs/**/*.json", "configs/app.json", true},
+		{"configs/**/*.json", "configs/prod/eu/app.json", true},
+		{"configs/**/*.json", "other/app.json", false},
+		{"**", "a/b/c", true},
+		{"**/tmp/**", "a/tmp/b/c", true},
+		{"**/tmp/**", "tmp", true},
+		{"**/tmp/**", "a/tmpx/b", false},
+		{"a/**", "a", true},
+		{"**/*.go", "main.go", true},
+		{"*.{json,yaml}", "app.yaml", true},
+		{"*.{json,yaml}", "app.toml", false},
+		{"{a,b/c}/*.txt", "b/c/x.txt", true},
+		{"{a,{b,c}d}.txt", "cd.txt", true},
+		{"file[0-9].log", "file7.log", true},
+		{"file[!0-9].log", "file7.log", false},
+		{"file[!0-9].log", "filex.log", true},
+		{"file[^0-9].log", "filex.log", true},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{"./configs/*.json", "configs/app.json", true},
+	}
+	for _, tt := range tests {
+		got, err := MatchGlob(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("MatchGlob(%q, %q): %v", tt.pattern, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "{a,b", "[a-", "a/[", `a\`} {
+		if _, err := CompileGlob(pattern); !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("CompileGlob(%q): expected path.ErrBadPattern, got %v", pattern, err)
+		}
+	}
+}
+
+func TestGlobSet(t *testing.T) {
+	set, err := NewGlobSet("configs/**/*.json", "!**/tmp/**", "configs/tmp/keep.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"configs/app.json":      true,
+		"configs/tmp/a.json":    false,
+		"configs/x/tmp/a.json":  false,
+		"configs/tmp/keep.json": true,
+		"configs/app.yaml":      false,
+	} {
+		if got := set.Match(name); got != want {
+			t.Errorf("Match(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	// A set starting with an exclusion includes everything else.
+	set, _ = NewGlobSet("!*.tmp")
+	if !set.Match("a/b.txt") || set.Match("b.tmp") {
+		t.Error("unexpected matches for an exclusion-only set")
+	}
+}
+
+// recordFS records the directories read through it.
+type recordFS struct {
+	fstest.MapFS
+	read []string
+}
+
+func (r *recordFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	r.read = append(r.read, name)
+	return r.MapFS.ReadDir(name)
+}
+
+func TestGlobFS(t *testing.T) {
+	fsys := &recordFS{MapFS: fstest.MapFS{
+		"configs/app.json":         {},
+		"configs/prod/db.json":     {},
+		"configs/prod/notes.txt":   {},
+		"configs/tmp/cache.json":   {},
+		"data/large/0001.bin":      {},
+		"data/large/deep/0002.bin": {},
+	}}
+	matches, err := GlobFS(fsys, "configs/**/*.json", "!**/tmp/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"configs/app.json", "configs/prod/db.json"}
+	if !equalStrings(matches, want) {
+		t.Errorf("expected %v, got %v", want, matches)
+	}
+	// Non-matching subtrees are not read.
+	if len(fsys.read) == 0 {
+		t.Fatal("expected directories to be read through ReadDir")
+	}
+	for _, dir := range fsys.read {
+		if dir == "data" || dir == "configs/tmp" {
+			t.Errorf("unexpected read of %s", dir)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{
+		"a.db":         "",
+		"sub/b.db":     "",
+		"cache/c.db":   "",
+		"sub/notes.md": "",
+	})
+	matches, err := Glob(dir, "**/*.db", "!cache/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.db"), filepath.Join(dir, "sub", "b.db")}
+	if !equalStrings(matches, want) {
+		t.Errorf("expected %v, got %v", want, matches)
+	}
+	if _, err := Glob(dir, "{a"); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+	if _, err := Glob(filepath.Join(dir, "missing"), "*"); err == nil {
+		t.Error("expected an error for a missing root")
+	}
+}
@@ -0,0 +1,354 @@
+package goutils
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvOption configures LoadEnv.
+type EnvOption func(*envOptions)
+
+// envOptions holds the settings collected from EnvOption values.
+type envOptions struct {
+	prefix string
+	lookup func(string) (string, bool, error)
+}
+
+// WithEnvironment resolves the variables from env instead of the process environment.
+// <NAME>_FILE variables of env are still supported (see ResolveEnvFrom).
+func WithEnvironment(env Environment) EnvOption {
+	return func(o *envOptions) {
+		o.lookup = func(name string) (string, bool, error) {
+			return ResolveEnvFrom(env, name)
+		}
+	}
+}
+
+// WithEnvPrefix prepends prefix to the names of all the variables, such as "APP_".
+func WithEnvPrefix(prefix string) EnvOption {
+	return func(o *envOptions) {
+		o.prefix = prefix
+	}
+}
+
+var ipNetType = reflect.TypeOf(net.IPNet{})
+
+// LoadEnv populates the struct pointed to by v from environment variables, as described
+// by the tags of its fields:
+//
+//   - env:"PORT" is the name of the variable; fields without it are ignored, except structs.
+//   - default:"8080" is used when the variable is unset or empty.
+//   - required:"true" reports an error when the variable is unset or empty and has no default.
+//   - sep:"," separates the items of slices and maps (default ","); map items are "key:value".
+//   - format:"bytes" parses integers with ConvertToBytes, such as "10MiB"; format:"ip",
+//     format:"cidr" and format:"ipcidr" validate strings with IsIPOrCIDR.
+//   - envPrefix:"DB_" on a struct field prefixes the variables of its fields.
+//
+// Supported field types are strings, booleans, numbers, time.Duration (via ParseDuration),
+// net.IP, net.IPNet, types implementing encoding.TextUnmarshaler, and slices, maps and
+// pointers of these. Nested structs are populated recursively; nil struct pointers are only
+// allocated if one of their variables is set.
+//
+// Variables are looked up with ResolveEnv, so <NAME>_FILE variables can provide their values.
+// All the problems are returned together, as an error joining one error per variable.
+//
+// Example:
+//
+//	type Config struct {
+//		Port     int           `env:"PORT" default:"8080"`
+//		Timeout  time.Duration `env:"TIMEOUT" default:"30s"`
+//		MaxBody  int64         `env:"MAX_BODY" default:"10MiB" format:"bytes"`
+//		Trusted  []string      `env:"TRUSTED_PROXIES" format:"ipcidr"`
+//		Database struct {
+//			URL string `env:"URL" required:"true"`
+//		} `envPrefix:"DB_"`
+//	}
+//
+//	var cfg Config
+//	if err := LoadEnv(&cfg, WithEnvPrefix("APP_")); err != nil {
+//		log.Fatal(err)
+//	}
+func LoadEnv(v any, opts ...EnvOption) error {
+	o := envOptions{lookup: ResolveEnv}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return errors.New("env target must be a pointer to a struct")
+	}
+	l := &envLoader{o: o}
+	l.loadStruct(target.Elem(), o.prefix)
+	return errors.Join(l.errs...)
+}
+
+// envLoader holds the state of a LoadEnv call.
+type envLoader struct {
+	o    envOptions
+	errs []error
+}
+
+// loadStruct populates the fields of the struct v, and reports whether any variable was set.
+func (l *envLoader) loadStruct(v reflect.Value, prefix string) bool {
+	set := false
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, tagged := field.Tag.Lookup("env")
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			if l.loadNested(v.Field(i), prefix+field.Tag.Get("envPrefix")) {
+				set = true
+			}
+			continue
+		}
+		if l.loadField(v.Field(i), field, prefix+name) {
+			set = true
+		}
+	}
+	return set
+}
+
+// loadNested populates an untagged struct or struct pointer field, and reports whether
+// any variable was set.
+func (l *envLoader) loadNested(v reflect.Value, prefix string) bool {
+	switch {
+	case v.Kind() == reflect.Struct && !isTextUnmarshaler(v.Type()):
+		return l.loadStruct(v, prefix)
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct && !isTextUnmarshaler(v.Type().Elem()):
+		if !v.IsNil() {
+			return l.loadStruct(v.Elem(), prefix)
+		}
+		elem := reflect.New(v.Type().Elem())
+		if !l.loadStruct(elem.Elem(), prefix) {
+			return false
+		}
+		v.Set(elem)
+		return true
+	}
+	return false
+}
+
+// loadField sets a tagged field from the variable name or its default, and reports whether
+// it was set from the variable, so that a default alone does not allocate a nested struct.
+func (l *envLoader) loadField(v reflect.Value, field reflect.StructField, name string) bool {
+	value, ok, err := l.o.lookup(name)
+	if err != nil {
+		l.errs = append(l.errs, err)
+		return false
+	}
+	fromEnv := ok && value != ""
+	if !fromEnv {
+		value, ok = field.Tag.Lookup("default")
+	}
+	if !ok || value == "" {
+		if field.Tag.Get("required") == "true" {
+			l.errs = append(l.errs, fmt.Errorf("required environment variable %s is not set", name))
+		}
+		return false
+	}
+	sep := field.Tag.Get("sep")
+	if sep == "" {
+		sep = ","
+	}
+	if err := setEnvValue(v, value, sep, field.Tag.Get("format")); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("invalid value for environment variable %s: %w", name, err))
+		return false
+	}
+	return fromEnv
+}
+
+// isTextUnmarshaler reports whether pointers to t implement encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setEnvValue parses s into v according to its type.
+func setEnvValue(v reflect.Value, s, sep, format string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		elem := reflect.New(t.Elem())
+		if err := setEnvValue(elem.Elem(), s, sep, format); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if isTextUnmarshaler(t) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch {
+	case t == durationType:
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case t == ipNetType:
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*ipNet))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if err := validateEnvFormat(s, format); err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := parseEnvInt(s, format)
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("value %s out of range", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := parseEnvUint(s, format)
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("value %s out of range", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		items := splitEnvList(s, sep)
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		var errs []error
+		for i, item := range items {
+			if err := setEnvValue(slice.Index(i), item, sep, format); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(t)
+		var errs []error
+		for _, item := range splitEnvList(s, sep) {
+			key, value, ok := strings.Cut(item, ":")
+			if !ok {
+				errs = append(errs, fmt.Errorf("invalid map item %q: expected key:value", item))
+				continue
+			}
+			k := reflect.New(t.Key()).Elem()
+			e := reflect.New(t.Elem()).Elem()
+			if err := setEnvValue(k, strings.TrimSpace(key), sep, ""); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := setEnvValue(e, strings.TrimSpace(value), sep, format); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			m.SetMapIndex(k, e)
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+// splitEnvList splits a list on sep, trimming spaces and dropping empty items.
+func splitEnvList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseEnvInt parses an integer, or a byte size with the "bytes" format.
+func parseEnvInt(s, format string) (int64, error) {
+	if format != "bytes" {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	// ConvertToBytes requires a unit, so plain numbers are bytes.
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	return ConvertToBytes(s)
+}
+
+// parseEnvUint parses an unsigned integer, with a size unit if format is "bytes".
+func parseEnvUint(s, format string) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err == nil || format != "bytes" {
+		return n, err
+	}
+	size, err := ConvertToBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("value %s out of range", s)
+	}
+	return uint64(size), nil
+}
+
+// validateEnvFormat checks a string against the "ip", "cidr" or "ipcidr" format.
+func validateEnvFormat(s, format string) error {
+	if format == "" || format == "bytes" {
+		return nil
+	}
+	isIP, isCIDR := IsIPOrCIDR(s)
+	switch format {
+	case "ip":
+		if !isIP {
+			return fmt.Errorf("%q is not an IP address", s)
+		}
+	case "cidr":
+		if !isCIDR {
+			return fmt.Errorf("%q is not a CIDR", s)
+		}
+	case "ipcidr":
+		if !isIP && !isCIDR {
+			return fmt.Errorf("%q is not an IP address or CIDR", s)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package goutils
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEnvDatabase struct {
+	URL      string `env:"URL" required:"true"`
+	MaxConns int    `env:"MAX_CONNS" default:"10"`
+}
+
+type testEnvConfig struct {
+	Port     int               `env:"PORT" default:"8080"`
+	Debug    bool              `env:"DEBUG"`
+	Ratio    float64           `env:"RATIO" default:"0.5"`
+	Timeout  time.Duration     `env:"TIMEOUT" default:"30s"`
+	MaxBody  int64             `env:"MAX_BODY" default:"10MiB" format:"bytes"`
+	Hosts    []string          `env:"HOSTS" sep:";"`
+	Ports    []int             `env:"PORTS"`
+	Labels   map[string]string `env:"LABELS"`
+	Name     *string           `env:"NAME"`
+	Missing  *string           `env:"MISSING"`
+	Trusted  []string          `env:"TRUSTED" format:"ipcidr"`
+	IP       net.IP            `env:"IP"`
+	Network  net.IPNet         `env:"NETWORK"`
+	Addr     netip.Addr        `env:"ADDR"`
+	Database testEnvDatabase   `envPrefix:"DB_"`
+	Cache    *struct {
+		Size int `env:"SIZE"`
+	} `envPrefix:"CACHE_"`
+	Metrics *struct {
+		Enabled  bool          `env:"ENABLED"`
+		Interval time.Duration `env:"INTERVAL" default:"10s"`
+	} `envPrefix:"METRICS_"`
+	Quota   uint64 `env:"QUOTA"`
+	Ignored string
+}
+
+func TestLoadEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"TESTENV_PORT":         "9090",
+		"TESTENV_DEBUG":        "true",
+		"TESTENV_HOSTS":        "a.example; b.example",
+		"TESTENV_PORTS":        "80,443",
+		"TESTENV_LABELS":       "env:prod, team:core",
+		"TESTENV_NAME":         "app",
+		"TESTENV_TRUSTED":      "10.0.0.1,192.168.0.0/16",
+		"TESTENV_IP":           "127.0.0.1",
+		"TESTENV_NETWORK":      "10.0.0.0/8",
+		"TESTENV_ADDR":         "::1",
+		"TESTENV_DB_URL":       "postgres://localhost/app",
+		"TESTENV_CACHE_SIZE":   "64",
+		"TESTENV_METRICS_SIZE": "unused",
+		"TESTENV_QUOTA":        "18446744073709551615",
+	} {
+		t.Setenv(name, value)
+	}
+
+	var cfg testEnvConfig
+	if err := LoadEnv(&cfg, WithEnvPrefix("TESTENV_")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9090 || !cfg.Debug || cfg.Ratio != 0.5 || cfg.Timeout != 30*time.Second || cfg.MaxBody != 10<<20 {
+		t.Errorf("unexpected scalar values %+v", cfg)
+	}
+	if !equalStrings(cfg.Hosts, []string{"a.example", "b.example"}) || len(cfg.Ports) != 2 || cfg.Ports[1] != 443 {
+		t.Errorf("unexpected slices %v %v", cfg.Hosts, cfg.Ports)
+	}
+	if cfg.Labels["env"] != "prod" || cfg.Labels["team"] != "core" {
+		t.Errorf("unexpected map %v", cfg.Labels)
+	}
+	if cfg.Name == nil || *cfg.Name != "app" || cfg.Missing != nil {
+		t.Errorf("unexpected pointers %v %v", cfg.Name, cfg.Missing)
+	}
+	if !cfg.IP.Equal(net.ParseIP("127.0.0.1")) || cfg.Network.String() != "10.0.0.0/8" || cfg.Addr != netip.MustParseAddr("::1") {
+		t.Errorf("unexpected addresses %v %v %v", cfg.IP, cfg.Network, cfg.Addr)
+	}
+	if cfg.Database.URL != "postgres://localhost/app" || cfg.Database.MaxConns != 10 {
+		t.Errorf("unexpected nested struct %+v", cfg.Database)
+	}
+	if cfg.Cache == nil || cfg.Cache.Size != 64 {
+		t.Errorf("expected the cache to be allocated, got %+v", cfg.Cache)
+	}
+	if cfg.Metrics != nil {
+		t.Errorf("expected the metrics to stay nil despite their defaults, got %+v", cfg.Metrics)
+	}
+	if cfg.Quota != 1<<64-1 {
+		t.Errorf("expected the maximum uint64, got %d", cfg.Quota)
+	}
+}
+
+func TestLoadEnvErrors(t *testing.T) {
+	t.Setenv("TESTENV_PORT", "http")
+	t.Setenv("TESTENV_TRUSTED", "10.0.0.1,not-an-ip")
+	t.Setenv("TESTENV_MAX_BODY", "lots")
+	t.Setenv("TESTENV_DB_URL", "")
+
+	var cfg testEnvConfig
+	err := LoadEnv(&cfg, WithEnvPrefix("TESTENV_"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, name := range []string{"TESTENV_PORT", "TESTENV_TRUSTED", "TESTENV_MAX_BODY", "required environment variable TESTENV_DB_URL"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected the error to mention %s, got %v", name, err)
+		}
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 4 {
+		t.Errorf("expected 4 aggregated errors, got %v", err)
+	}
+
+	if err := LoadEnv(cfg); err == nil {
+		t.Error("expected an error for a non-pointer target")
+	}
+}
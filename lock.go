@@ -0,0 +1,216 @@
+package goutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned when a lock is held by another process.
+var ErrLocked = errors.New("file is locked")
+
+// LockOption configures the acquisition of a FileLock.
+type LockOption func(*lockOptions)
+
+// lockOptions holds the settings collected from LockOption values.
+type lockOptions struct {
+	shared bool
+}
+
+// WithSharedLock acquires a shared lock, which can be held by several processes at once
+// but excludes exclusive locks. Locks are exclusive by default.
+func WithSharedLock() LockOption {
+	return func(o *lockOptions) {
+		o.shared = true
+	}
+}
+
+// LockInfo describes the holder of an exclusive lock, as recorded in the lock file.
+type LockInfo struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Acquired time.Time `json:"acquired"`
+	// Stale is set when the recorded holder no longer holds the lock, for example
+	// because it crashed before releasing it.
+	Stale bool `json:"-"`
+}
+
+// FileLock is an advisory lock on a file (flock on Unix, LockFileEx on Windows),
+// released automatically by the operating system if the process exits.
+//
+// Locks are held per FileLock: two FileLock values conflict even within the same process.
+type FileLock struct {
+	mu     sync.Mutex
+	file   *os.File
+	path   string
+	shared bool
+}
+
+// LockFile acquires a lock on the file at path, creating it if needed, and waits until it is available.
+//
+// The holder of an exclusive lock is recorded in the file for diagnostics, see ReadLockInfo.
+// The lock file is not removed on Unlock, since removing it would let two processes lock
+// different files under the same path.
+func LockFile(path string, opts ...LockOption) (*FileLock, error) {
+	return acquireLock(path, false, opts)
+}
+
+// TryLockFile acquires a lock on the file at path without waiting.
+// It returns an error wrapping ErrLocked if the lock is held by another process.
+func TryLockFile(path string, opts ...LockOption) (*FileLock, error) {
+	return acquireLock(path, true, opts)
+}
+
+// LockFileContext acquires a lock on the file at path, retrying until ctx is done.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	lock, err := LockFileContext(ctx, "/var/lib/app/backup.lock")
+//	if err != nil {
+//		return err
+//	}
+//	defer lock.Unlock()
+func LockFileContext(ctx context.Context, path string, opts ...LockOption) (*FileLock, error) {
+	delay := 10 * time.Millisecond
+	for {
+		lock, err := acquireLock(path, true, opts)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to acquire lock %s: %w: %w", path, ErrLocked, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 250*time.Millisecond)
+	}
+}
+
+// WithLock runs fn while holding an exclusive lock on the file at path.
+//
+// Example:
+//
+//	err := WithLock("data/.lock", func() error {
+//		return WriteToFile("data/state.json", state)
+//	})
+func WithLock(path string, fn func() error) (err error) {
+	lock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer func(lock *FileLock) {
+		if unlockErr := lock.Unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}(lock)
+	return fn()
+}
+
+// acquireLock opens the lock file and locks it.
+func acquireLock(path string, nonBlocking bool, opts []LockOption) (*FileLock, error) {
+	var o lockOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(file, !o.shared, nonBlocking); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", path, err)
+	}
+	lock := &FileLock{file: file, path: path, shared: o.shared}
+	if !o.shared {
+		if err := lock.writeInfo(); err != nil {
+			_ = lock.Unlock()
+			return nil, err
+		}
+	}
+	return lock, nil
+}
+
+// writeInfo records the current process as the holder of the lock.
+func (l *FileLock) writeInfo() error {
+	hostname, _ := os.Hostname()
+	data, err := json.Marshal(LockInfo{PID: os.Getpid(), Hostname: hostname, Acquired: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(append(data, '\n'), 0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// Path returns the path of the lock file.
+func (l *FileLock) Path() string {
+	return l.path
+}
+
+// Unlock releases the lock. Calling it more than once is a no-op.
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	if !l.shared {
+		// Clear the holder, so it is not reported as stale.
+		_ = l.file.Truncate(0)
+	}
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// ReadLockInfo returns the holder recorded in the lock file, or nil if none is recorded.
+//
+// The lock is reported as stale when it is no longer held, or when the holder is a process
+// of this host that no longer exists.
+func ReadLockInfo(path string) (*LockInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	data, err := io.ReadAll(io.LimitReader(file, 4096))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+
+	// A shared lock only succeeds if no exclusive lock is held.
+	if err := lockFile(file, false, true); err == nil {
+		_ = unlockFile(file)
+		info.Stale = true
+	} else if hostname, _ := os.Hostname(); hostname == info.Hostname && !processExists(info.PID) {
+		info.Stale = true
+	}
+	return &info, nil
+}
@@ -0,0 +1,23 @@
+//go:build (!unix && !windows) || aix || solaris
+
+package goutils
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is not supported on this platform.
+func lockFile(*os.File, bool, bool) error {
+	return errors.ErrUnsupported
+}
+
+// unlockFile is not supported on this platform.
+func unlockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+// processExists assumes the process exists, since it cannot be checked on this platform.
+func processExists(int) bool {
+	return true
+}
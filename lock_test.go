@@ -0,0 +1,119 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	lock, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("Error acquiring lock: %v", err)
+	}
+	if _, err := TryLockFile(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if _, err := TryLockFile(path, WithSharedLock()); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected a shared lock to be refused, got %v", err)
+	}
+
+	info, err := ReadLockInfo(path)
+	if err != nil {
+		t.Fatalf("Error reading lock info: %v", err)
+	}
+	if info == nil || info.PID != os.Getpid() || info.Stale {
+		t.Errorf("Unexpected lock info: %+v", info)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := LockFileContext(ctx, path); !errors.Is(err, ErrLocked) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = lock.Unlock()
+	}()
+	waited, err := LockFileContext(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Error waiting for lock: %v", err)
+	}
+	if err := waited.Unlock(); err != nil {
+		t.Errorf("Error releasing lock: %v", err)
+	}
+	if err := waited.Unlock(); err != nil {
+		t.Errorf("Expected a second Unlock to be a no-op, got %v", err)
+	}
+}
+
+func TestSharedFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	first, err := TryLockFile(path, WithSharedLock())
+	if err != nil {
+		t.Fatalf("Error acquiring shared lock: %v", err)
+	}
+	defer first.Unlock()
+	second, err := TryLockFile(path, WithSharedLock())
+	if err != nil {
+		t.Fatalf("Expected shared locks to be compatible, got %v", err)
+	}
+	defer second.Unlock()
+	if _, err := TryLockFile(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected an exclusive lock to be refused, got %v", err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	hostname, _ := os.Hostname()
+	path := writeTestFile(t, t.TempDir(), "app.lock",
+		[]byte(fmt.Sprintf(`{"pid": 999999999, "hostname": %q, "acquired": "2024-01-01T00:00:00Z"}`, hostname)))
+	info, err := ReadLockInfo(path)
+	if err != nil {
+		t.Fatalf("Error reading lock info: %v", err)
+	}
+	if info == nil || !info.Stale {
+		t.Errorf("Expected a stale lock, got %+v", info)
+	}
+
+	lock, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be acquired, got %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ReadLockInfo(path); err != nil || info != nil {
+		t.Errorf("Expected the holder to be cleared, got %+v (%v)", info, err)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".lock")
+	err := WithLock(path, func() error {
+		if _, err := TryLockFile(path); !errors.Is(err, ErrLocked) {
+			t.Errorf("Expected the lock to be held, got %v", err)
+		}
+		return WriteToFile(filepath.Join(dir, "state.json"), "{}")
+	})
+	if err != nil {
+		t.Fatalf("Error running with lock: %v", err)
+	}
+
+	failure := errors.New("failure")
+	if err := WithLock(path, func() error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("Expected the function error, got %v", err)
+	}
+	lock, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("Expected the lock to be released, got %v", err)
+	}
+	_ = lock.Unlock()
+}
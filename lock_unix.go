@@ -0,0 +1,41 @@
+//go:build unix && !aix && !solaris
+
+package goutils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile locks the file with flock, returning ErrLocked if nonBlocking and the lock is held.
+func lockFile(f *os.File, exclusive, nonBlocking bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if nonBlocking {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		switch {
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return ErrLocked
+		}
+		return err
+	}
+}
+
+// unlockFile releases the lock of the file.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// processExists reports whether a process with the PID exists on this host.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package goutils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	// errorLockViolation is the ERROR_LOCK_VIOLATION Windows error code.
+	errorLockViolation = syscall.Errno(33)
+)
+
+// lockRange returns the locked byte range, placed beyond the content since Windows locks
+// are mandatory and would otherwise prevent reading the holder information.
+func lockRange() *syscall.Overlapped {
+	return &syscall.Overlapped{OffsetHigh: 0x7fffffff}
+}
+
+// lockFile locks the file with LockFileEx, returning ErrLocked if nonBlocking and the lock is held.
+func lockFile(f *os.File, exclusive, nonBlocking bool) error {
+	var flags uintptr
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	if nonBlocking {
+		flags |= lockfileFailImmediately
+	}
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(lockRange())))
+	if r != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return ErrLocked
+	}
+	return err
+}
+
+// unlockFile releases the lock of the file.
+func unlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRange())))
+	if r != 0 {
+		return nil
+	}
+	return err
+}
+
+// processExists reports whether a process with the PID exists on this host.
+func processExists(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Access denied means the process exists.
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	_ = syscall.CloseHandle(h)
+	return true
+}
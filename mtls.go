@@ -0,0 +1,125 @@
+package goutils
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// clientIdentityKey is the context key under which the ClientIdentity is stored.
+type clientIdentityKey struct{}
+
+// ClientIdentity is the identity of a client authenticated with a verified TLS certificate.
+type ClientIdentity struct {
+	CommonName  string            // Subject common name
+	Subject     string            // Distinguished name of the subject
+	DNSNames    []string          // DNS subject alternative names
+	URIs        []string          // URI subject alternative names
+	SPIFFEID    string            // SPIFFE ID ("spiffe://..." URI SAN), if any
+	Fingerprint string            // SHA-256 fingerprint, colon-separated
+	Certificate *x509.Certificate // The verified leaf certificate
+}
+
+// ClientPolicy lists the clients allowed by RequireClientIdentity.
+// A client is allowed if it matches any subject or any SAN pattern.
+type ClientPolicy struct {
+	// Subjects are allowed common names or full distinguished names (e.g., "CN=api,O=Example"),
+	// compared exactly, including case, with ClientIdentity.CommonName and ClientIdentity.Subject.
+	Subjects []string
+	// SANs are patterns matched against DNS and URI SANs using path.Match syntax,
+	// e.g., "*.internal.example.com" or "spiffe://example.org/ns/prod/sa/*".
+	SANs []string
+}
+
+// ClientIdentityFromRequest extracts the identity from the verified client certificate chain.
+// It returns false if the connection is not TLS or the client certificate was not verified.
+func ClientIdentityFromRequest(r *http.Request) (*ClientIdentity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	identity := &ClientIdentity{
+		CommonName:  cert.Subject.CommonName,
+		Subject:     cert.Subject.String(),
+		DNSNames:    cert.DNSNames,
+		Fingerprint: CertificateFingerprint(cert),
+		Certificate: cert,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+		if uri.Scheme == "spiffe" && identity.SPIFFEID == "" {
+			identity.SPIFFEID = uri.String()
+		}
+	}
+	return identity, true
+}
+
+// ClientIdentityFromContext returns the identity stored by ClientIdentityMiddleware.
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return identity, ok
+}
+
+// ClientIdentityMiddleware stores the verified client identity, if any, in the request context.
+func ClientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := ClientIdentityFromRequest(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireClientIdentity returns a middleware that only allows clients matching the policy.
+// Other requests are rejected with 403 Forbidden and the reason in the response body.
+//
+// Example:
+//
+//	mux.Handle("/admin", RequireClientIdentity(ClientPolicy{
+//		SANs: []string{"spiffe://example.org/ns/prod/sa/admin"},
+//	})(adminHandler))
+func RequireClientIdentity(policy ClientPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := ClientIdentityFromContext(r.Context())
+			if !ok {
+				if identity, ok = ClientIdentityFromRequest(r); ok {
+					r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+				}
+			}
+			if !ok {
+				http.Error(w, "Forbidden: a verified client certificate is required", http.StatusForbidden)
+				return
+			}
+			if !policy.Allows(identity) {
+				http.Error(w, "Forbidden: client certificate "+identity.Subject+" is not authorized", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Allows reports whether the identity matches any subject or SAN pattern of the policy.
+func (p ClientPolicy) Allows(identity *ClientIdentity) bool {
+	for _, subject := range p.Subjects {
+		if subject == identity.CommonName || subject == identity.Subject {
+			return true
+		}
+	}
+	for _, pattern := range p.SANs {
+		for _, san := range identity.DNSNames {
+			if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(san)); matched {
+				return true
+			}
+		}
+		for _, san := range identity.URIs {
+			if matched, _ := path.Match(pattern, san); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package goutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRequireClientIdentity(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, "web", "web.internal.example.com")
+	certs, err := ParseCertificates(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := certs[0]
+	cert.URIs = []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ns/prod/sa/web"}}
+
+	handler := ClientIdentityMiddleware(RequireClientIdentity(ClientPolicy{
+		SANs: []string{"spiffe://example.org/ns/prod/sa/*"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := ClientIdentityFromContext(r.Context())
+		_, _ = io.WriteString(w, identity.SPIFFEID)
+	})))
+
+	tests := []struct {
+		name   string
+		state  *tls.ConnectionState
+		status int
+	}{
+		{"no TLS", nil, http.StatusForbidden},
+		{"unverified", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusForbidden},
+		{"verified", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = test.state
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", test.name, test.status, rec.Code, rec.Body)
+		}
+	}
+
+	policies := []struct {
+		policy  ClientPolicy
+		allowed bool
+	}{
+		{ClientPolicy{Subjects: []string{"web"}}, true},
+		{ClientPolicy{Subjects: []string{"CN=web"}}, true},
+		{ClientPolicy{Subjects: []string{"WEB"}}, false},
+		{ClientPolicy{Subjects: []string{"cn=web"}}, false},
+		{ClientPolicy{SANs: []string{"*.internal.example.com"}}, true},
+		{ClientPolicy{SANs: []string{"spiffe://example.org/ns/dev/sa/*"}}, false},
+		{ClientPolicy{Subjects: []string{"api"}}, false},
+		{ClientPolicy{}, false},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	identity, _ := ClientIdentityFromRequest(req)
+	for _, test := range policies {
+		if allowed := test.policy.Allows(identity); allowed != test.allowed {
+			t.Errorf("Policy %+v: expected %v, got %v", test.policy, test.allowed, allowed)
+		}
+	}
+}
+
+func TestClientIdentityOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "localhost", "localhost")
+	clientCert, clientKey := ca.issue(t, "client")
+
+	config, err := NewTLSConfig(
+		WithCertificate(string(serverCert), string(serverKey)),
+		WithClientCAs(writeTestFile(t, dir, "ca.crt", ca.certPEM)),
+		WithClientAuth(tls.RequireAndVerifyClientCert),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(ClientIdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := ClientIdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, "no identity", http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, identity.CommonName)
+	})))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{pair},
+		ServerName:   "localhost",
+	}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "client" {
+		t.Errorf("Expected client identity, got %q", body)
+	}
+}
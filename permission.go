@@ -0,0 +1,461 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Unix permission bits, as used by chmod.
+const (
+	modeSetuid = 0o4000
+	modeSetgid = 0o2000
+	modeSticky = 0o1000
+)
+
+// ModeExpr is a file mode expression accepted by chmod: an octal mode such as "0755",
+// or a symbolic expression such as "u+rwx,g-w,o=".
+type ModeExpr struct {
+	expr    string
+	octal   bool
+	mode    uint32
+	clauses []modeClause
+}
+
+// modeClause is a symbolic clause, such as "go-w".
+type modeClause struct {
+	who uint32 // bits of the affected classes, or 0 for all classes
+	ops []modeOp
+}
+
+// modeOp is an operation of a symbolic clause, such as "-w".
+type modeOp struct {
+	op    byte   // '+', '-' or '='
+	perms string // letters among "rwxXst", or a single class letter among "ugo"
+}
+
+// ParseMode parses an octal mode such as "0755" or "755", or a symbolic mode of comma-separated
+// clauses such as "u+rwx,g-w,o=" or "a+X". Unlike chmod, a clause without classes ("+x")
+// applies to all classes regardless of the umask.
+//
+// Example:
+//
+//	expr, err := ParseMode("go-w")
+//	if err != nil {
+//		return err
+//	}
+//	mode := expr.Apply(info.Mode())
+func ParseMode(expr string) (ModeExpr, error) {
+	e := ModeExpr{expr: expr}
+	if expr == "" {
+		return e, errors.New("invalid mode: empty expression")
+	}
+	if octal := strings.TrimPrefix(expr, "0o"); isOctal(octal) {
+		if len(octal) > 4 {
+			return e, fmt.Errorf("invalid mode %q: too many digits", expr)
+		}
+		mode, _ := strconv.ParseUint(octal, 8, 32)
+		e.octal, e.mode = true, uint32(mode)
+		return e, nil
+	}
+	for _, part := range strings.Split(expr, ",") {
+		clause, err := parseModeClause(part)
+		if err != nil {
+			return e, fmt.Errorf("invalid mode %q: %w", expr, err)
+		}
+		e.clauses = append(e.clauses, clause)
+	}
+	return e, nil
+}
+
+// isOctal reports whether s only contains octal digits.
+func isOctal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '7' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseModeClause parses a symbolic clause such as "ug+rw-x".
+func parseModeClause(s string) (modeClause, error) {
+	var c modeClause
+	i := 0
+	for ; i < len(s) && strings.IndexByte("ugoa", s[i]) >= 0; i++ {
+		switch s[i] {
+		case 'u':
+			c.who |= 0o700 | modeSetuid
+		case 'g':
+			c.who |= 0o070 | modeSetgid
+		case 'o':
+			c.who |= 0o007 | modeSticky
+		case 'a':
+			c.who |= 0o777 | modeSetuid | modeSetgid | modeSticky
+		}
+	}
+	if i == len(s) {
+		return c, fmt.Errorf("missing operator in %q", s)
+	}
+	for i < len(s) {
+		op := modeOp{op: s[i]}
+		if strings.IndexByte("+-=", op.op) < 0 {
+			return c, fmt.Errorf("unexpected %q in %q", s[i], s)
+		}
+		i++
+		start := i
+		for ; i < len(s) && strings.IndexByte("+-=", s[i]) < 0; i++ {
+		}
+		op.perms = s[start:i]
+		if len(op.perms) == 1 && strings.Contains("ugo", op.perms) {
+			c.ops = append(c.ops, op)
+			continue
+		}
+		for _, r := range op.perms {
+			if !strings.ContainsRune("rwxXst", r) {
+				return c, fmt.Errorf("unexpected %q in %q", r, s)
+			}
+		}
+		c.ops = append(c.ops, op)
+	}
+	return c, nil
+}
+
+// String returns the expression as parsed.
+func (e ModeExpr) String() string {
+	return e.expr
+}
+
+// Apply returns mode changed by the expression. The file type bits of mode are preserved,
+// and are used to evaluate "X", which only sets execute permissions on directories and
+// on files already executable by some class.
+func (e ModeExpr) Apply(mode fs.FileMode) fs.FileMode {
+	if e.octal {
+		return fromUnixMode(mode.Type(), e.mode)
+	}
+	bits := toUnixMode(mode)
+	for _, c := range e.clauses {
+		for _, op := range c.ops {
+			bits = c.apply(op, bits, mode.IsDir())
+		}
+	}
+	return fromUnixMode(mode.Type(), bits)
+}
+
+// apply applies an operation of the clause to the permission bits.
+func (c modeClause) apply(op modeOp, bits uint32, isDir bool) uint32 {
+	who := c.who
+	if who == 0 {
+		who = 0o777 | modeSetuid | modeSetgid | modeSticky
+	}
+	var perms uint32
+	switch op.perms {
+	case "u":
+		perms = (bits >> 6 & 0o7) * 0o111
+	case "g":
+		perms = (bits >> 3 & 0o7) * 0o111
+	case "o":
+		perms = (bits & 0o7) * 0o111
+	default:
+		for _, r := range op.perms {
+			switch r {
+			case 'r':
+				perms |= 0o444
+			case 'w':
+				perms |= 0o222
+			case 'x':
+				perms |= 0o111
+			case 'X':
+				if isDir || bits&0o111 != 0 {
+					perms |= 0o111
+				}
+			case 's':
+				perms |= modeSetuid | modeSetgid
+			case 't':
+				perms |= modeSticky
+			}
+		}
+	}
+	perms &= who
+	switch op.op {
+	case '+':
+		bits |= perms
+	case '-':
+		bits &^= perms
+	case '=':
+		bits = bits&^who | perms
+	}
+	return bits
+}
+
+// toUnixMode returns the permission bits of mode in the Unix representation.
+func toUnixMode(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= modeSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= modeSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= modeSticky
+	}
+	return bits
+}
+
+// fromUnixMode returns the mode with the file type typ and the Unix permission bits.
+func fromUnixMode(typ fs.FileMode, bits uint32) fs.FileMode {
+	mode := typ | fs.FileMode(bits&0o777)
+	if bits&modeSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if bits&modeSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if bits&modeSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// ModeOption configures ChangeMode.
+type ModeOption func(*modeOptions)
+
+// modeOptions holds the settings collected from ModeOption values.
+type modeOptions struct {
+	recursive bool
+	fileMode  string
+	dirMode   string
+	owner     string
+	dryRun    func(ModeChange)
+}
+
+// WithModeRecursive applies the changes to the content of directories, like chmod -R.
+// Symbolic links inside the tree are not followed.
+func WithModeRecursive() ModeOption {
+	return func(o *modeOptions) {
+		o.recursive = true
+	}
+}
+
+// WithModeForFiles applies the mode expression to files instead of the mode passed to ChangeMode.
+func WithModeForFiles(mode string) ModeOption {
+	return func(o *modeOptions) {
+		o.fileMode = mode
+	}
+}
+
+// WithModeForDirs applies the mode expression to directories instead of the mode passed to ChangeMode.
+func WithModeForDirs(mode string) ModeOption {
+	return func(o *modeOptions) {
+		o.dirMode = mode
+	}
+}
+
+// WithOwner also changes the owner, given as "user", "user:group", ":group" or "user:"
+// (the login group of user), where user and group are names or numeric IDs.
+func WithOwner(owner string) ModeOption {
+	return func(o *modeOptions) {
+		o.owner = owner
+	}
+}
+
+// WithModeDryRun reports each intended change to report instead of applying it.
+func WithModeDryRun(report func(ModeChange)) ModeOption {
+	return func(o *modeOptions) {
+		o.dryRun = report
+	}
+}
+
+// ModeChange describes a change performed, or planned in dry-run mode, by ChangeMode.
+// Owner IDs are -1 when unknown or unchanged.
+type ModeChange struct {
+	Path    string
+	OldMode fs.FileMode
+	NewMode fs.FileMode
+	OldUID  int
+	OldGID  int
+	NewUID  int
+	NewGID  int
+}
+
+// ChangeMode changes the mode of the file at path, given as an octal or symbolic expression
+// (see ParseMode). Only files whose mode or owner differ are changed.
+// With WithModeRecursive, errors do not stop the walk and are returned together.
+//
+// The mode may be empty when only WithModeForFiles, WithModeForDirs or WithOwner are used.
+//
+// Example:
+//
+//	// chmod -R u=rwX,go=rX /srv/site && chown -R www-data: /srv/site
+//	err := ChangeMode("/srv/site", "u=rwX,go=rX",
+//		WithModeRecursive(),
+//		WithOwner("www-data:"),
+//	)
+//
+//	// Directories 0755 and files 0644.
+//	err = ChangeMode("/srv/site", "", WithModeRecursive(), WithModeForDirs("0755"), WithModeForFiles("0644"))
+func ChangeMode(path, mode string, opts ...ModeOption) error {
+	o := modeOptions{fileMode: mode, dirMode: mode}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &modeChanger{o: o, uid: -1, gid: -1}
+	var err error
+	if c.file, err = parseOptionalMode(o.fileMode); err != nil {
+		return err
+	}
+	if c.dir, err = parseOptionalMode(o.dirMode); err != nil {
+		return err
+	}
+	if o.owner != "" {
+		if c.uid, c.gid, err = lookupOwner(o.owner); err != nil {
+			return err
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !o.recursive || !info.IsDir() {
+		return c.change(path, info)
+	}
+	// Walk the target of a symbolic link given as path.
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	var errs []error
+	err = filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		entryInfo, err := entry.Info()
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if err := c.change(p, entryInfo); err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// parseOptionalMode parses a mode expression, returning nil for an empty one.
+func parseOptionalMode(mode string) (*ModeExpr, error) {
+	if mode == "" {
+		return nil, nil
+	}
+	expr, err := ParseMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return &expr, nil
+}
+
+// lookupOwner resolves an owner such as "user:group" to IDs, -1 meaning unchanged.
+func lookupOwner(owner string) (uid, gid int, err error) {
+	name, group, hasGroup := strings.Cut(owner, ":")
+	uid, gid = -1, -1
+	var u *user.User
+	if name != "" {
+		if uid, err = strconv.Atoi(name); err != nil {
+			if u, err = user.Lookup(name); err != nil {
+				return -1, -1, fmt.Errorf("failed to look up user %q: %w", name, err)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("user %q has no numeric ID", name)
+			}
+		}
+	}
+	switch {
+	case group != "":
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("failed to look up group %q: %w", group, err)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("group %q has no numeric ID", group)
+			}
+		}
+	case hasGroup && name != "":
+		// "user:" selects the login group of the user.
+		if u == nil {
+			if u, err = user.LookupId(name); err != nil {
+				return -1, -1, fmt.Errorf("failed to look up user %q: %w", name, err)
+			}
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return -1, -1, fmt.Errorf("user %q has no numeric group ID", name)
+		}
+	}
+	return uid, gid, nil
+}
+
+// modeChanger holds the state of a ChangeMode call.
+type modeChanger struct {
+	o        modeOptions
+	file     *ModeExpr
+	dir      *ModeExpr
+	uid, gid int
+}
+
+// change applies the mode and owner to the file described by info, if they differ.
+func (c *modeChanger) change(path string, info fs.FileInfo) error {
+	expr := c.file
+	if info.IsDir() {
+		expr = c.dir
+	}
+	change := ModeChange{Path: path, OldMode: info.Mode(), NewMode: info.Mode(), OldUID: -1, OldGID: -1, NewUID: -1, NewGID: -1}
+	if expr != nil {
+		change.NewMode = expr.Apply(info.Mode())
+	}
+	if c.uid >= 0 || c.gid >= 0 {
+		uid, gid, ok := fileOwner(info)
+		if !ok {
+			uid, gid = -1, -1
+		}
+		if c.uid >= 0 && c.uid != uid {
+			change.OldUID, change.NewUID = uid, c.uid
+		}
+		if c.gid >= 0 && c.gid != gid {
+			change.OldGID, change.NewGID = gid, c.gid
+		}
+	}
+	ownerChanged := change.NewUID >= 0 || change.NewGID >= 0
+	if change.NewMode == change.OldMode && !ownerChanged {
+		return nil
+	}
+	if c.o.dryRun != nil {
+		c.o.dryRun(change)
+		return nil
+	}
+	// Change the owner first, since it may clear the setuid and setgid bits.
+	if ownerChanged {
+		if err := os.Chown(path, change.NewUID, change.NewGID); err != nil {
+			return err
+		}
+	}
+	special := fs.ModeSetuid | fs.ModeSetgid
+	if change.NewMode != change.OldMode || ownerChanged && change.NewMode&special != 0 {
+		if err := os.Chmod(path, change.NewMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
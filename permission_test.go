@@ -0,0 +1,147 @@
+package goutils
+
+import (
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		expr string
+		mode fs.FileMode
+		want fs.FileMode
+	}{
+		{"0755", 0600, 0755},
+		{"644", 0777, 0644},
+		{"4755", 0, fs.ModeSetuid | 0755},
+		{"u+x", 0644, 0744},
+		{"u+rwx,g-w,o=", 0666, 0740},
+		{"go-w", 0777, 0755},
+		{"a=r", 0777, 0444},
+		{"+x", 0600, 0711},
+		{"a+X", 0644, 0644},
+		{"a+X", 0744, 0755},
+		{"a+X", fs.ModeDir | 0644, fs.ModeDir | 0755},
+		{"g=u", 0750, 0770},
+		{"u=rw,go=r", 0777, 0644},
+		{"u+s", 0755, fs.ModeSetuid | 0755},
+		{"+t", fs.ModeDir | 0777, fs.ModeDir | fs.ModeSticky | 0777},
+		{"u+r-w", 0200, 0400},
+	}
+	for _, tt := range tests {
+		expr, err := ParseMode(tt.expr)
+		if err != nil {
+			t.Errorf("ParseMode(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Apply(tt.mode); got != tt.want {
+			t.Errorf("ParseMode(%q).Apply(%v) = %v, want %v", tt.expr, tt.mode, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "755x", "0789", "077777", "u", "u+q", "z+x", "u+x,"} {
+		if _, err := ParseMode(expr); err == nil {
+			t.Errorf("ParseMode(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestChangeModeRecursive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions are not supported on Windows")
+	}
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{
+		"a.txt":     "a",
+		"sub/b.txt": "b",
+	})
+	err := ChangeMode(dir, "", WithModeRecursive(), WithModeForDirs("0750"), WithModeForFiles("u=rw,go="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]fs.FileMode{
+		".":         fs.ModeDir | 0750,
+		"sub":       fs.ModeDir | 0750,
+		"a.txt":     0600,
+		"sub/b.txt": 0600,
+	} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s: expected %v, got %v", name, want, info.Mode())
+		}
+	}
+
+	// Only the files whose mode differs are reported.
+	var changes []ModeChange
+	err = ChangeMode(dir, "go+r", WithModeRecursive(), WithModeForDirs("0750"), WithModeDryRun(func(c ModeChange) {
+		changes = append(changes, c)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.OldMode != 0600 || c.NewMode != 0644 {
+			t.Errorf("unexpected change %+v", c)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "a.txt")); info.Mode() != 0600 {
+		t.Errorf("dry run changed the mode to %v", info.Mode())
+	}
+
+	if err := ChangeMode(dir, "u+q"); err == nil {
+		t.Error("expected an error for an invalid mode")
+	}
+}
+
+func TestChangeModeOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix ownership is not supported on Windows")
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("current user unknown: %v", err)
+	}
+	path := writeTestFile(t, t.TempDir(), "file.txt", []byte("data"))
+
+	// Changing to the current owner is a no-op, by name or by ID.
+	for _, owner := range []string{current.Username, current.Uid + ":" + current.Gid, current.Uid + ":"} {
+		var changes []ModeChange
+		err := ChangeMode(path, "", WithOwner(owner), WithModeDryRun(func(c ModeChange) {
+			changes = append(changes, c)
+		}))
+		if err != nil {
+			t.Fatalf("%s: %v", owner, err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("%s: unexpected changes %+v", owner, changes)
+		}
+	}
+
+	// Report a change to another group without applying it.
+	gid, _ := strconv.Atoi(current.Gid)
+	var changes []ModeChange
+	err = ChangeMode(path, "0600", WithOwner(":"+strconv.Itoa(gid+1)), WithModeDryRun(func(c ModeChange) {
+		changes = append(changes, c)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].NewGID != gid+1 || changes[0].OldGID != gid || changes[0].NewUID != -1 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	if err := ChangeMode(path, "", WithOwner("no-such-user-goutils")); err == nil {
+		t.Error("expected an error for an unknown user")
+	}
+}
@@ -0,0 +1,574 @@
+package goutils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCertificateRevoked is returned when a certificate has been revoked by its issuer.
+var ErrCertificateRevoked = errors.New("certificate has been revoked")
+
+// RevocationPolicy defines how to handle certificates whose revocation status cannot be determined.
+type RevocationPolicy int
+
+const (
+	// RevocationSoftFail accepts certificates whose status cannot be determined.
+	RevocationSoftFail RevocationPolicy = iota
+	// RevocationHardFail rejects certificates whose status cannot be determined.
+	RevocationHardFail
+)
+
+const (
+	defaultCRLRefreshInterval = time.Hour
+	defaultOCSPCacheTTL       = time.Hour
+	crlRetryInterval          = time.Minute
+	maxRevocationResponseSize = 10 << 20
+	// maxOCSPCacheEntries bounds the OCSP cache of a long-running server.
+	maxOCSPCacheEntries = 10000
+)
+
+var (
+	oidOCSPBasic         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	ocspSignatureAlgOIDs = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+		"1.3.101.112":           x509.PureEd25519,
+	}
+)
+
+// RevocationConfig configures a RevocationChecker.
+type RevocationConfig struct {
+	// CRLs are local file paths or HTTP(S) URLs of certificate revocation lists (DER or PEM).
+	CRLs []string
+	// CRLRefreshInterval is how often CRLs are reloaded (default 1h).
+	// A CRL whose NextUpdate has passed is reloaded as soon as possible.
+	CRLRefreshInterval time.Duration
+	// OCSP enables OCSP checking for certificates not covered by a CRL.
+	OCSP bool
+	// OCSPServer overrides the OCSP responder URL listed in certificates.
+	OCSPServer string
+	// OCSPCacheTTL is how long responses without NextUpdate are cached (default 1h).
+	// Expired answers are dropped, and at most 10000 certificates are cached.
+	OCSPCacheTTL time.Duration
+	// Policy defines how to handle certificates whose status cannot be determined.
+	Policy RevocationPolicy
+	// HTTPClient is used to fetch CRLs and query OCSP responders (default: 10s timeout).
+	HTTPClient *http.Client
+}
+
+// RevocationChecker checks client certificates against CRLs and OCSP responders.
+// It is safe for concurrent use. Due CRL refreshes run in the background, using the previous
+// CRLs meanwhile, and concurrent OCSP queries for the same certificate are shared, so TLS
+// handshakes never wait behind a slow download of another one.
+type RevocationChecker struct {
+	config RevocationConfig
+
+	mu          sync.Mutex
+	crls        map[string]*crlEntry
+	lastRefresh time.Time
+	refreshing  bool
+	ocspCache   map[string]ocspCacheEntry
+	ocspCalls   map[string]*ocspCall
+}
+
+// crlEntry is a loaded CRL with its revoked serial numbers.
+type crlEntry struct {
+	list    *x509.RevocationList
+	revoked map[string]struct{}
+}
+
+// ocspCacheEntry is a cached OCSP answer.
+type ocspCacheEntry struct {
+	revoked bool
+	expires time.Time
+}
+
+// ocspCall is an OCSP query in progress, shared by the concurrent checks of a certificate.
+type ocspCall struct {
+	done   chan struct{}
+	status revocationStatus
+	err    error
+}
+
+// revocationStatus is the outcome of a single revocation source.
+type revocationStatus int
+
+const (
+	statusUnknown revocationStatus = iota
+	statusGood
+	statusRevoked
+)
+
+// NewRevocationChecker creates a checker and loads the configured CRLs.
+func NewRevocationChecker(config RevocationConfig) (*RevocationChecker, error) {
+	if config.CRLRefreshInterval <= 0 {
+		config.CRLRefreshInterval = defaultCRLRefreshInterval
+	}
+	if config.OCSPCacheTTL <= 0 {
+		config.OCSPCacheTTL = defaultOCSPCacheTTL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.CRLs) == 0 && !config.OCSP {
+		return nil, errors.New("at least one CRL or OCSP checking is required")
+	}
+	c := &RevocationChecker{
+		config:    config,
+		crls:      make(map[string]*crlEntry),
+		ocspCache: make(map[string]ocspCacheEntry),
+		ocspCalls: make(map[string]*ocspCall),
+	}
+	if err := c.RefreshCRLs(); err != nil && config.Policy == RevocationHardFail {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RefreshCRLs reloads all configured CRLs. A CRL that fails to load keeps its previous version.
+// Checks running meanwhile keep using the previous CRLs.
+func (c *RevocationChecker) RefreshCRLs() error {
+	c.mu.Lock()
+	c.lastRefresh = time.Now()
+	c.mu.Unlock()
+	return c.refreshCRLs()
+}
+
+// refreshCRLs loads the CRLs without holding the lock, then swaps them in under it.
+func (c *RevocationChecker) refreshCRLs() error {
+	loaded := make(map[string]*crlEntry, len(c.config.CRLs))
+	var errs []error
+	for _, source := range c.config.CRLs {
+		list, err := c.loadCRL(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load CRL %s: %w", source, err))
+			continue
+		}
+		entry := &crlEntry{list: list, revoked: make(map[string]struct{}, len(list.RevokedCertificateEntries))}
+		for _, revoked := range list.RevokedCertificateEntries {
+			entry.revoked[revoked.SerialNumber.Text(16)] = struct{}{}
+		}
+		loaded[source] = entry
+	}
+	c.mu.Lock()
+	for source, entry := range loaded {
+		c.crls[source] = entry
+	}
+	c.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// loadCRL reads and parses a CRL from a file or an HTTP(S) URL.
+func (c *RevocationChecker) loadCRL(source string) (*x509.RevocationList, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = c.fetch(http.MethodGet, source, "", nil)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+// fetch performs an HTTP request and returns the response body.
+func (c *RevocationChecker) fetch(method, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRevocationResponseSize))
+}
+
+// VerifyConnection checks the revocation status of the verified client certificate.
+// It is meant to be used as tls.Config.VerifyConnection, which also runs on resumed sessions.
+func (c *RevocationChecker) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 {
+		return nil
+	}
+	return c.CheckChain(cs.VerifiedChains[0])
+}
+
+// CheckChain checks the revocation status of the leaf certificate of a verified chain.
+func (c *RevocationChecker) CheckChain(chain []*x509.Certificate) error {
+	if len(chain) < 2 {
+		// A self-signed certificate cannot be revoked by an issuer.
+		return nil
+	}
+	return c.Check(chain[0], chain[1])
+}
+
+// Check checks the revocation status of cert, issued by issuer, using the CRLs first,
+// then OCSP if enabled. It returns ErrCertificateRevoked if the certificate is revoked.
+// When the status cannot be determined, it returns an error only under RevocationHardFail.
+func (c *RevocationChecker) Check(cert, issuer *x509.Certificate) error {
+	status, err := c.crlStatus(cert, issuer)
+	if status == statusUnknown && c.config.OCSP {
+		var ocspErr error
+		status, ocspErr = c.ocspStatus(cert, issuer)
+		err = errors.Join(err, ocspErr)
+	}
+	switch {
+	case status == statusRevoked:
+		return fmt.Errorf("%w: serial %s", ErrCertificateRevoked, cert.SerialNumber.Text(16))
+	case status == statusUnknown && c.config.Policy == RevocationHardFail:
+		if err == nil {
+			err = errors.New("no CRL or OCSP responder available")
+		}
+		return fmt.Errorf("failed to check revocation status: %w", err)
+	default:
+		return nil
+	}
+}
+
+// crlStatus looks up the certificate in the CRLs of its issuer.
+func (c *RevocationChecker) crlStatus(cert, issuer *x509.Certificate) (revocationStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stale := false
+	for _, entry := range c.crls {
+		if !entry.list.NextUpdate.IsZero() && now.After(entry.list.NextUpdate) {
+			stale = true
+		}
+	}
+	// Refresh in the background, so handshakes never wait for a CRL download:
+	// meanwhile, the previous CRLs are used.
+	if since := now.Sub(c.lastRefresh); !c.refreshing && (since >= c.config.CRLRefreshInterval || (stale && since >= crlRetryInterval)) {
+		c.lastRefresh = now
+		c.refreshing = true
+		go func() {
+			if err := c.refreshCRLs(); err != nil {
+				_, _ = fmt.Fprintf(defaultErrorWriter, "Warning: %v\n", err)
+			}
+			c.mu.Lock()
+			c.refreshing = false
+			c.mu.Unlock()
+		}()
+	}
+
+	var err error
+	for _, entry := range c.crls {
+		if !bytes.Equal(entry.list.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if sigErr := entry.list.CheckSignatureFrom(issuer); sigErr != nil {
+			err = fmt.Errorf("invalid CRL signature: %w", sigErr)
+			continue
+		}
+		if !entry.list.NextUpdate.IsZero() && now.After(entry.list.NextUpdate) {
+			err = errors.New("CRL has expired")
+			continue
+		}
+		if _, revoked := entry.revoked[cert.SerialNumber.Text(16)]; revoked {
+			return statusRevoked, nil
+		}
+		return statusGood, nil
+	}
+	return statusUnknown, err
+}
+
+// ocspCertID is the CertID structure (RFC 6960).
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+// ocspRequest is the OCSPRequest structure without signature (RFC 6960).
+type ocspRequest struct {
+	TBSRequest struct {
+		RequestList []struct {
+			Cert ocspCertID
+		}
+	}
+}
+
+// ocspResponse is the OCSPResponse structure (RFC 6960).
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspBasicResponse is the BasicOCSPResponse structure (RFC 6960).
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspResponseData is the ResponseData structure (RFC 6960).
+type ocspResponseData struct {
+	Raw         asn1.RawContent
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+// ocspSingleResponse is the SingleResponse structure (RFC 6960).
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// ocspRevokedInfo is the RevokedInfo structure (RFC 6960).
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspStatus queries the OCSP responder, using cached answers when possible.
+func (c *RevocationChecker) ocspStatus(cert, issuer *x509.Certificate) (revocationStatus, error) {
+	certID, err := newOCSPCertID(cert, issuer)
+	if err != nil {
+		return statusUnknown, err
+	}
+	key := hex.EncodeToString(certID.IssuerKeyHash) + ":" + cert.SerialNumber.Text(16)
+
+	c.mu.Lock()
+	if cached, ok := c.ocspCache[key]; ok {
+		if time.Now().Before(cached.expires) {
+			c.mu.Unlock()
+			if cached.revoked {
+				return statusRevoked, nil
+			}
+			return statusGood, nil
+		}
+		delete(c.ocspCache, key)
+	}
+	// Concurrent cache misses for the same certificate share a single query.
+	if call, ok := c.ocspCalls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.status, call.err
+	}
+	call := &ocspCall{done: make(chan struct{})}
+	c.ocspCalls[key] = call
+	c.mu.Unlock()
+
+	call.status, call.err = c.queryOCSP(cert, issuer, certID, key)
+	c.mu.Lock()
+	delete(c.ocspCalls, key)
+	c.mu.Unlock()
+	close(call.done)
+	return call.status, call.err
+}
+
+// queryOCSP queries the OCSP responder of cert, and caches the answer under key.
+func (c *RevocationChecker) queryOCSP(cert, issuer *x509.Certificate, certID ocspCertID, key string) (revocationStatus, error) {
+	server := c.config.OCSPServer
+	if server == "" && len(cert.OCSPServer) > 0 {
+		server = cert.OCSPServer[0]
+	}
+	if server == "" {
+		return statusUnknown, errors.New("certificate has no OCSP responder")
+	}
+
+	var req ocspRequest
+	req.TBSRequest.RequestList = append(req.TBSRequest.RequestList, struct{ Cert ocspCertID }{certID})
+	body, err := asn1.Marshal(req)
+	if err != nil {
+		return statusUnknown, err
+	}
+	data, err := c.fetch(http.MethodPost, server, "application/ocsp-request", body)
+	if err != nil {
+		return statusUnknown, fmt.Errorf("OCSP request failed: %w", err)
+	}
+	single, err := parseOCSPResponse(data, certID, issuer)
+	if err != nil {
+		return statusUnknown, err
+	}
+
+	now := time.Now()
+	if single.ThisUpdate.After(now.Add(5 * time.Minute)) {
+		return statusUnknown, errors.New("OCSP response is not yet valid")
+	}
+	expires := now.Add(c.config.OCSPCacheTTL)
+	if !single.NextUpdate.IsZero() {
+		if now.After(single.NextUpdate) {
+			return statusUnknown, errors.New("OCSP response has expired")
+		}
+		expires = single.NextUpdate
+	}
+
+	var status revocationStatus
+	switch {
+	case bool(single.Good):
+		status = statusGood
+	case !single.Revoked.RevocationTime.IsZero():
+		status = statusRevoked
+	default:
+		return statusUnknown, errors.New("OCSP responder does not know the certificate")
+	}
+	c.mu.Lock()
+	c.cacheOCSP(key, ocspCacheEntry{revoked: status == statusRevoked, expires: expires}, now)
+	c.mu.Unlock()
+	return status, nil
+}
+
+// cacheOCSP stores an OCSP answer. When the cache is full, expired answers are dropped
+// first, then arbitrary ones. The caller must hold the lock.
+func (c *RevocationChecker) cacheOCSP(key string, entry ocspCacheEntry, now time.Time) {
+	if _, ok := c.ocspCache[key]; !ok && len(c.ocspCache) >= maxOCSPCacheEntries {
+		for k, cached := range c.ocspCache {
+			if !now.Before(cached.expires) {
+				delete(c.ocspCache, k)
+			}
+		}
+		for k := range c.ocspCache {
+			if len(c.ocspCache) < maxOCSPCacheEntries {
+				break
+			}
+			delete(c.ocspCache, k)
+		}
+	}
+	c.ocspCache[key] = entry
+}
+
+// newOCSPCertID builds the CertID identifying cert, using SHA-1 as required by most responders.
+func newOCSPCertID(cert, issuer *x509.Certificate) (ocspCertID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return ocspCertID{}, fmt.Errorf("failed to parse issuer public key: %w", err)
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+	return ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash[:],
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+// parseOCSPResponse parses a DER OCSP response, verifies its signature and returns the
+// single response matching certID.
+func parseOCSPResponse(data []byte, certID ocspCertID, issuer *x509.Certificate) (*ocspSingleResponse, error) {
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("OCSP responder returned status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, fmt.Errorf("unsupported OCSP response type: %s", resp.Response.ResponseType)
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+
+	signer, err := ocspSigner(basic, issuer)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, ok := ocspSignatureAlgOIDs[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported OCSP signature algorithm: %s", basic.SignatureAlgorithm.Algorithm)
+	}
+	if err := signer.CheckSignature(algorithm, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response signature: %w", err)
+	}
+
+	for i, single := range basic.TBSResponseData.Responses {
+		if single.CertID.SerialNumber.Cmp(certID.SerialNumber) == 0 &&
+			bytes.Equal(single.CertID.NameHash, certID.NameHash) &&
+			bytes.Equal(single.CertID.IssuerKeyHash, certID.IssuerKeyHash) {
+			return &basic.TBSResponseData.Responses[i], nil
+		}
+	}
+	return nil, errors.New("OCSP response does not contain the certificate")
+}
+
+// ocspSigner returns the certificate that signed the response: the issuer itself or a
+// delegated responder certificate issued by it for OCSP signing.
+func ocspSigner(basic ocspBasicResponse, issuer *x509.Certificate) (*x509.Certificate, error) {
+	if len(basic.Certificates) == 0 {
+		return issuer, nil
+	}
+	responder, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP responder certificate: %w", err)
+	}
+	if responder.Equal(issuer) {
+		return issuer, nil
+	}
+	if err := responder.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("OCSP responder certificate is not issued by the CA: %w", err)
+	}
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return responder, nil
+		}
+	}
+	return nil, errors.New("OCSP responder certificate is not authorized for OCSP signing")
+}
+
+// WithRevocationChecker rejects client certificates revoked according to the checker.
+//
+// LoadTLSConfig takes no options; with it, set the checker on the returned configuration:
+//
+//	config, err := LoadTLSConfig("server.crt", "server.key", "ca.crt", true)
+//	if err != nil {
+//		return err
+//	}
+//	config.VerifyConnection = checker.VerifyConnection
+func WithRevocationChecker(checker *RevocationChecker) TLSOption {
+	return func(o *tlsOptions) {
+		o.revocationChecker = checker
+	}
+}
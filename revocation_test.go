@@ -0,0 +1,304 @@
+package goutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testOCSPSingleResponse is a SingleResponse with a raw CertStatus, used to build responses.
+type testOCSPSingleResponse struct {
+	CertID     ocspCertID
+	Status     asn1.RawValue
+	ThisUpdate time.Time `asn1:"generalized"`
+	NextUpdate time.Time `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+// testOCSPResponseData is a ResponseData identifying the responder by key hash.
+type testOCSPResponseData struct {
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []testOCSPSingleResponse
+}
+
+// newTestOCSPResponder starts a stand-in OCSP responder signing with the CA key.
+// Certificates whose serial number is in revoked are reported as revoked.
+func newTestOCSPResponder(t *testing.T, ca *testCA, revoked map[string]bool, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mustMarshal := func(v any, params string) []byte {
+		der, err := asn1.MarshalWithParams(v, params)
+		if err != nil {
+			t.Error(err)
+		}
+		return der
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil || len(req.TBSRequest.RequestList) == 0 {
+			http.Error(w, "malformed request", http.StatusBadRequest)
+			return
+		}
+		certID := req.TBSRequest.RequestList[0].Cert
+		status := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0}
+		if revoked[certID.SerialNumber.Text(16)] {
+			status = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true,
+				Bytes: mustMarshal(time.Now().Add(-time.Minute).UTC(), "generalized")}
+		}
+		keyHash := sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo)
+		tbs := mustMarshal(testOCSPResponseData{
+			ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: mustMarshal(keyHash[:20], "")},
+			ProducedAt:  time.Now().UTC(),
+			Responses: []testOCSPSingleResponse{{
+				CertID:     certID,
+				Status:     status,
+				ThisUpdate: time.Now().Add(-time.Minute).UTC(),
+				NextUpdate: time.Now().Add(time.Hour).UTC(),
+			}},
+		}, "")
+		digest := sha256.Sum256(tbs)
+		signature, err := ecdsa.SignASN1(rand.Reader, ca.key, digest[:])
+		if err != nil {
+			t.Error(err)
+		}
+		basic := mustMarshal(struct {
+			TBSResponseData    asn1.RawValue
+			SignatureAlgorithm pkix.AlgorithmIdentifier
+			Signature          asn1.BitString
+		}{
+			TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+		}, "")
+		var resp ocspResponse
+		resp.Response.ResponseType = oidOCSPBasic
+		resp.Response.Response = basic
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(mustMarshal(resp, ""))
+	}))
+}
+
+// issueParsed issues a certificate and returns it parsed.
+func (ca *testCA) issueParsed(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+	certPEM, _ := ca.issue(t, commonName)
+	certs, err := ParseCertificates(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certs[0]
+}
+
+func TestRevocationCheckerCRL(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	good := ca.issueParsed(t, "good")
+	revoked := ca.issueParsed(t, "revoked")
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: revoked.SerialNumber, RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Error creating CRL: %v", err)
+	}
+	crlFile := writeTestFile(t, dir, "ca.crl", crl)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(crl)
+	}))
+	defer server.Close()
+
+	for _, source := range []string{crlFile, server.URL + "/ca.crl"} {
+		checker, err := NewRevocationChecker(RevocationConfig{CRLs: []string{source}, Policy: RevocationHardFail})
+		if err != nil {
+			t.Fatalf("Error creating checker for %s: %v", source, err)
+		}
+		if err := checker.Check(good, ca.cert); err != nil {
+			t.Errorf("%s: expected good certificate, got %v", source, err)
+		}
+		if err := checker.CheckChain([]*x509.Certificate{revoked, ca.cert}); !errors.Is(err, ErrCertificateRevoked) {
+			t.Errorf("%s: expected revoked certificate, got %v", source, err)
+		}
+		other := newTestCA(t)
+		if err := checker.Check(other.issueParsed(t, "other"), other.cert); err == nil {
+			t.Errorf("%s: expected hard-fail for a certificate not covered by a trusted CRL", source)
+		}
+	}
+}
+
+func TestRevocationCheckerOCSP(t *testing.T) {
+	ca := newTestCA(t)
+	good := ca.issueParsed(t, "good")
+	revoked := ca.issueParsed(t, "revoked")
+
+	var requests atomic.Int32
+	responder := newTestOCSPResponder(t, ca, map[string]bool{revoked.SerialNumber.Text(16): true}, &requests)
+	defer responder.Close()
+
+	checker, err := NewRevocationChecker(RevocationConfig{OCSP: true, OCSPServer: responder.URL, Policy: RevocationHardFail})
+	if err != nil {
+		t.Fatalf("Error creating checker: %v", err)
+	}
+	if err := checker.Check(good, ca.cert); err != nil {
+		t.Errorf("Expected good certificate, got %v", err)
+	}
+	if err := checker.Check(good, ca.cert); err != nil {
+		t.Errorf("Expected cached good certificate, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected the OCSP response to be cached, got %d requests", requests.Load())
+	}
+	if err := checker.Check(revoked, ca.cert); !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("Expected revoked certificate, got %v", err)
+	}
+
+	// A response signed by another CA must not be trusted.
+	other := newTestCA(t)
+	if err := checker.Check(other.issueParsed(t, "forged"), other.cert); err == nil {
+		t.Errorf("Expected an error for a response with an invalid signature")
+	}
+
+	responder.Close()
+	soft, err := NewRevocationChecker(RevocationConfig{OCSP: true, OCSPServer: responder.URL})
+	if err != nil {
+		t.Fatalf("Error creating checker: %v", err)
+	}
+	if err := soft.Check(good, ca.cert); err != nil {
+		t.Errorf("Expected soft-fail to accept an unreachable responder, got %v", err)
+	}
+	hard, err := NewRevocationChecker(RevocationConfig{OCSP: true, OCSPServer: responder.URL, Policy: RevocationHardFail})
+	if err != nil {
+		t.Fatalf("Error creating checker: %v", err)
+	}
+	if err := hard.Check(good, ca.cert); err == nil {
+		t.Errorf("Expected hard-fail to reject an unreachable responder")
+	}
+
+	config, err := NewTLSConfig(
+		WithCertificateStore(&CertificateStore{}),
+		WithRevocationChecker(checker),
+	)
+	if err != nil {
+		t.Fatalf("Error creating TLS config: %v", err)
+	}
+	cs := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{revoked, ca.cert}}}
+	if err := config.VerifyConnection(cs); !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("Expected VerifyConnection to reject the revoked certificate, got %v", err)
+	}
+}
+
+func TestRevocationCheckerConcurrency(t *testing.T) {
+	ca := newTestCA(t)
+	good := ca.issueParsed(t, "good")
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Error creating CRL: %v", err)
+	}
+
+	// After the initial load, CRL downloads hang until released.
+	release := make(chan struct{})
+	var crlRequests atomic.Int32
+	crlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if crlRequests.Add(1) > 1 {
+			<-release
+		}
+		_, _ = w.Write(crl)
+	}))
+	defer crlServer.Close()
+	defer close(release)
+
+	checker, err := NewRevocationChecker(RevocationConfig{
+		CRLs:               []string{crlServer.URL},
+		CRLRefreshInterval: time.Millisecond,
+		Policy:             RevocationHardFail,
+	})
+	if err != nil {
+		t.Fatalf("Error creating checker: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- checker.Check(good, ca.cert) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the previous CRL to be used during the refresh, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Check not to wait for the CRL download")
+	}
+
+	// Concurrent cache misses for the same certificate share one OCSP query.
+	var requests atomic.Int32
+	responder := newTestOCSPResponder(t, ca, nil, &requests)
+	defer responder.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		resp, err := http.Post(responder.URL, r.Header.Get("Content-Type"), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer slow.Close()
+	ocsp, err := NewRevocationChecker(RevocationConfig{OCSP: true, OCSPServer: slow.URL, Policy: RevocationHardFail})
+	if err != nil {
+		t.Fatalf("Error creating checker: %v", err)
+	}
+	errs := make(chan error, 8)
+	for range 8 {
+		go func() { errs <- ocsp.Check(good, ca.cert) }()
+	}
+	for range 8 {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected good certificate, got %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a single OCSP request, got %d", requests.Load())
+	}
+}
+
+func TestRevocationCheckerOCSPCacheBound(t *testing.T) {
+	c := &RevocationChecker{ocspCache: make(map[string]ocspCacheEntry)}
+	now := time.Now()
+	for i := range maxOCSPCacheEntries {
+		expires := now.Add(time.Hour)
+		if i%2 == 0 {
+			expires = now.Add(-time.Second)
+		}
+		c.cacheOCSP(big.NewInt(int64(i)).Text(16), ocspCacheEntry{expires: expires}, now)
+	}
+	c.cacheOCSP("new", ocspCacheEntry{expires: now.Add(time.Hour)}, now)
+	if len(c.ocspCache) != maxOCSPCacheEntries/2+1 {
+		t.Errorf("Expected the expired answers to be dropped, got %d entries", len(c.ocspCache))
+	}
+	for i := range maxOCSPCacheEntries {
+		c.cacheOCSP("fresh-"+big.NewInt(int64(i)).Text(16), ocspCacheEntry{expires: now.Add(time.Hour)}, now)
+	}
+	if len(c.ocspCache) != maxOCSPCacheEntries {
+		t.Errorf("Expected at most %d entries, got %d", maxOCSPCacheEntries, len(c.ocspCache))
+	}
+}
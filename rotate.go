@@ -0,0 +1,451 @@
+package goutils
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp format of rotated file names, in UTC.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOption configures a RotatingWriter.
+type RotateOption func(*rotateOptions)
+
+// rotateOptions holds the settings collected from RotateOption values.
+type rotateOptions struct {
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	mode       fs.FileMode
+	err        error
+}
+
+// WithRotateMaxSize rotates the file before it exceeds size, such as "100MiB".
+// Sizes use the ConvertToBytes syntax.
+func WithRotateMaxSize(size string) RotateOption {
+	return func(o *rotateOptions) {
+		n, err := ConvertToBytes(size)
+		if err == nil && n <= 0 {
+			err = errors.New("size must be positive")
+		}
+		if err != nil {
+			o.err = fmt.Errorf("invalid max size %q: %w", size, err)
+			return
+		}
+		o.maxSize = n
+	}
+}
+
+// WithRotateMaxAge rotates the file once it is older than age, such as "24h".
+// The age is checked on write, and measured from the modification time of an existing file
+// or the creation of a new one.
+func WithRotateMaxAge(age string) RotateOption {
+	return func(o *rotateOptions) {
+		d, err := ParseDuration(age)
+		if err == nil && d <= 0 {
+			err = errors.New("age must be positive")
+		}
+		if err != nil {
+			o.err = fmt.Errorf("invalid max age %q: %w", age, err)
+			return
+		}
+		o.maxAge = d
+	}
+}
+
+// WithRotateMaxBackups keeps at most n rotated files, removing the oldest ones.
+// All rotated files are kept by default.
+func WithRotateMaxBackups(n int) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxBackups = n
+	}
+}
+
+// WithRotateCompress compresses rotated files with gzip, in the background.
+func WithRotateCompress() RotateOption {
+	return func(o *rotateOptions) {
+		o.compress = true
+	}
+}
+
+// WithRotateFileMode sets the permissions of the log files. The default is 0600.
+func WithRotateFileMode(mode fs.FileMode) RotateOption {
+	return func(o *rotateOptions) {
+		o.mode = mode
+	}
+}
+
+// RotatingWriter is an io.WriteCloser writing to a log file that is rotated by size and age,
+// safe for concurrent use.
+//
+// Rotated files are renamed with their rotation time, such as app-2006-01-02T15-04-05.000.log
+// for app.log, and are optionally compressed and pruned in the background.
+type RotatingWriter struct {
+	mu     sync.Mutex
+	path   string
+	opts   rotateOptions
+	file   *os.File
+	size   int64
+	opened time.Time
+	last   time.Time
+	closed bool
+	mill   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRotatingWriter opens the log file at path for appending, creating it and its directory
+// if needed. Close it to flush the background compression.
+//
+// Example:
+//
+//	w, err := NewRotatingWriter("/var/log/app/app.log",
+//		WithRotateMaxSize("100MiB"),
+//		WithRotateMaxAge("24h"),
+//		WithRotateMaxBackups(7),
+//		WithRotateCompress(),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	w.ReopenOnSignal()
+//	log.SetOutput(w)
+func NewRotatingWriter(path string, opts ...RotateOption) (*RotatingWriter, error) {
+	o := rotateOptions{mode: defaultFileMode}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	w := &RotatingWriter{
+		path: path,
+		opts: o,
+		mill: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.runMill()
+	// Apply the retention to the backups of previous runs.
+	w.mill <- struct{}{}
+	return w, nil
+}
+
+// open opens the log file for appending. The caller must hold the lock.
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.opts.mode)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.opened = time.Now()
+	if w.size > 0 {
+		w.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write writes p to the log file, rotating it first if p would exceed the maximum size
+// or the file exceeds the maximum age. A write larger than the maximum size is written
+// whole to a new file.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	if w.file == nil {
+		// A previous rotation or reopen failed; try again.
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// shouldRotate reports whether writing n bytes requires a rotation first.
+func (w *RotatingWriter) shouldRotate(n int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.maxSize > 0 && w.size+int64(n) > w.opts.maxSize {
+		return true
+	}
+	return w.opts.maxAge > 0 && time.Since(w.opened) >= w.opts.maxAge
+}
+
+// Rotate rotates the log file, even if it has not reached its limits.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fs.ErrClosed
+	}
+	return w.rotate()
+}
+
+// rotate renames the log file to a backup and opens a new one. The caller must hold the lock.
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		// Close before renaming, as Windows cannot rename open files.
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+	}
+	backup := w.backupName()
+	if err := os.Rename(w.path, backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupName returns an unused name for the next backup.
+func (w *RotatingWriter) backupName() string {
+	t := time.Now().UTC().Truncate(time.Millisecond)
+	// Keep names unique and ordered when rotating several times per millisecond.
+	if !t.After(w.last) {
+		t = w.last.Add(time.Millisecond)
+	}
+	for {
+		name := w.backupPath(t)
+		if !FileExists(name) && !FileExists(name+".gz") {
+			w.last = t
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// backupPath returns the backup name for the rotation time t.
+func (w *RotatingWriter) backupPath(t time.Time) string {
+	dir, base := filepath.Split(w.path)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// Reopen closes and reopens the log file, for use after it was moved by an external tool
+// such as logrotate.
+func (w *RotatingWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fs.ErrClosed
+	}
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+	}
+	return w.open()
+}
+
+// ReopenOnSignal reopens the log file whenever one of sigs is received, until the writer
+// is closed. Without arguments, it uses SIGHUP on platforms supporting it.
+func (w *RotatingWriter) ReopenOnSignal(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = reopenSignals
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(sigs) == 0 || w.closed {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				if err := w.Reopen(); err != nil && !errors.Is(err, fs.ErrClosed) {
+					_, _ = fmt.Fprintf(defaultErrorWriter, "Warning: %v\n", err)
+				}
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close closes the log file and waits for the background compression and pruning.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	close(w.mill)
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	return nil
+}
+
+// runMill compresses and prunes the backups after each rotation.
+func (w *RotatingWriter) runMill() {
+	defer w.wg.Done()
+	for range w.mill {
+		if err := w.millBackups(); err != nil {
+			_, _ = fmt.Fprintf(defaultErrorWriter, "Warning: %v\n", err)
+		}
+	}
+}
+
+// rotatedFile is a backup of the log file.
+type rotatedFile struct {
+	path    string
+	rotated time.Time
+}
+
+// millBackups compresses the uncompressed backups and removes the ones exceeding the retention.
+func (w *RotatingWriter) millBackups() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if w.opts.maxBackups > 0 && len(backups) > w.opts.maxBackups {
+		for _, b := range backups[w.opts.maxBackups:] {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("failed to remove old log file: %w", err))
+			}
+		}
+		backups = backups[:w.opts.maxBackups]
+	}
+	if w.opts.compress {
+		for _, b := range backups {
+			if strings.HasSuffix(b.path, ".gz") {
+				continue
+			}
+			if err := w.compress(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backups returns the backups of the log file, newest first.
+func (w *RotatingWriter) backups() ([]rotatedFile, error) {
+	dir, base := filepath.Split(w.path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log files: %w", err)
+	}
+	var backups []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		rotated, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, rotatedFile{path: filepath.Join(dir, name), rotated: rotated})
+	}
+	slices.SortFunc(backups, func(a, b rotatedFile) int {
+		return b.rotated.Compare(a.rotated)
+	})
+	return backups, nil
+}
+
+// compress replaces the backup at path with a gzip-compressed copy.
+func (w *RotatingWriter) compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	defer func(src *os.File) {
+		_ = src.Close()
+	}(src)
+
+	dst, err := NewAtomicWriter(path+".gz", WithFileMode(w.opts.mode))
+	if err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	defer func(dst *AtomicWriter) {
+		_ = dst.Close()
+	}(dst)
+
+	gz := gzip.NewWriter(dst)
+	gz.Name = filepath.Base(path)
+	if _, err := io.Copy(gz, src); err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	if err := dst.Commit(); err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	// Close before removing, as Windows cannot remove open files.
+	_ = src.Close()
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove compressed log file: %w", err)
+	}
+	return nil
+}
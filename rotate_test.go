@@ -0,0 +1,201 @@
+package goutils
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listBackups returns the rotated files of app.log in dir.
+func listBackups(t *testing.T, dir string) []string {
+	t.Helper()
+	var backups []string
+	for _, name := range listTestTree(t, dir) {
+		if strings.HasPrefix(name, "app-") {
+			backups = append(backups, name)
+		}
+	}
+	return backups
+}
+
+func TestRotatingWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "logs", "app.log")
+	w, err := NewRotatingWriter(logPath, WithRotateMaxSize("1K"), WithRotateMaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two lines fit in each file.
+	lines := make([]string, 7)
+	for i := range lines {
+		lines[i] = strings.Repeat(string(rune('a'+i)), 399) + "\n"
+		if _, err := w.Write([]byte(lines[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("closed")); err == nil {
+		t.Error("expected an error writing to a closed writer")
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil || string(data) != lines[6] {
+		t.Errorf("unexpected content %q, %v", data, err)
+	}
+	backups := listBackups(t, filepath.Join(dir, "logs"))
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	// Backups sort by rotation time, so the newest one is last.
+	data, _ = os.ReadFile(filepath.Join(dir, "logs", backups[1]))
+	if string(data) != lines[4]+lines[5] {
+		t.Errorf("unexpected backup content %q", data)
+	}
+}
+
+func TestRotatingWriterAge(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotatingWriter(logPath, WithRotateMaxAge("50ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(w *RotatingWriter) {
+		_ = w.Close()
+	}(w)
+	_, _ = w.Write([]byte("old\n"))
+	time.Sleep(60 * time.Millisecond)
+	_, _ = w.Write([]byte("new\n"))
+	if backups := listBackups(t, filepath.Dir(logPath)); len(backups) != 1 {
+		t.Errorf("expected 1 backup, got %v", backups)
+	}
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithRotateCompress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("rotated content\n"))
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := listBackups(t, dir)
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("expected 1 compressed backup, got %v", backups)
+	}
+	f, err := os.Open(filepath.Join(dir, backups[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(gz); string(data) != "rotated content\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestRotatingWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithRotateMaxSize("1KiB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("x", 99) + "\n"
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if _, err := w.Write([]byte(line)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+	for _, name := range listTestTree(t, dir) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1024 || len(data)%len(line) != 0 {
+			t.Errorf("%s has an unexpected size %d", name, len(data))
+		}
+		total += len(data)
+	}
+	if total != 8*50*len(line) {
+		t.Errorf("expected %d bytes, got %d", 8*50*len(line), total)
+	}
+}
+
+func TestRotatingWriterReopen(t *testing.T) {
+	if len(reopenSignals) == 0 {
+		t.Skip("no reopen signal on this platform")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(w *RotatingWriter) {
+		_ = w.Close()
+	}(w)
+	w.ReopenOnSignal()
+
+	_, _ = w.Write([]byte("before\n"))
+	// Simulate logrotate moving the file away.
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(reopenSignals[0]); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !FileExists(logPath) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, _ = w.Write([]byte("after\n"))
+	data, err := os.ReadFile(logPath)
+	if err != nil || string(data) != "after\n" {
+		t.Errorf("unexpected content %q, %v", data, err)
+	}
+}
+
+func TestRotatingWriterInvalidOptions(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	if _, err := NewRotatingWriter(logPath, WithRotateMaxSize("lots")); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+	if _, err := NewRotatingWriter(logPath, WithRotateMaxAge("-1h")); err == nil {
+		t.Error("expected an error for a negative age")
+	}
+}
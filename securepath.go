@@ -0,0 +1,276 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath is returned for untrusted paths that are refused, such as paths containing
+// NUL bytes, volume names, or absolute paths when WithRejectAbsolute is set.
+var ErrUnsafePath = errors.New("unsafe path")
+
+// maxSymlinks is the number of symbolic links followed before SecureJoin gives up.
+const maxSymlinks = 255
+
+// RootOption configures SecureJoin and OpenRootDir.
+type RootOption func(*rootOptions)
+
+// rootOptions holds the settings collected from RootOption values.
+type rootOptions struct {
+	rejectAbsolute bool
+}
+
+// WithRejectAbsolute refuses absolute untrusted paths with ErrUnsafePath, instead of
+// interpreting them relative to the root.
+func WithRejectAbsolute() RootOption {
+	return func(o *rootOptions) {
+		o.rejectAbsolute = true
+	}
+}
+
+// cleanUntrusted validates an untrusted path and returns it relative to the root.
+func cleanUntrusted(untrusted string, opts []RootOption) (string, error) {
+	var o rootOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if strings.ContainsRune(untrusted, 0) {
+		return "", fmt.Errorf("%w: contains a NUL byte", ErrUnsafePath)
+	}
+	if filepath.VolumeName(untrusted) != "" {
+		return "", fmt.Errorf("%w: contains a volume name: %q", ErrUnsafePath, untrusted)
+	}
+	if o.rejectAbsolute && (filepath.IsAbs(untrusted) || strings.HasPrefix(untrusted, "/")) {
+		return "", fmt.Errorf("%w: absolute path: %q", ErrUnsafePath, untrusted)
+	}
+	// Clean against a virtual root, so ".." cannot climb above it.
+	cleaned := filepath.Clean(string(filepath.Separator) + filepath.FromSlash(untrusted))
+	return strings.TrimLeft(cleaned, string(filepath.Separator)), nil
+}
+
+// SecureJoin joins the untrusted path to root, guaranteeing that the result is inside root.
+//
+// The path is resolved as if root were the filesystem root: ".." components cannot climb
+// above it, absolute paths are relative to it (unless WithRejectAbsolute is given), and
+// symbolic links inside root are evaluated with the same rules, so absolute or ".." targets
+// cannot escape either. Missing components are joined as is.
+//
+// The result can still be swapped by a concurrent process after SecureJoin returns;
+// use OpenRootDir when the directory may be modified by untrusted parties.
+//
+// Example:
+//
+//	filePath, err := SecureJoin("/srv/static", ParseURLPath(r.URL.Path))
+//	if err != nil {
+//		http.Error(w, "invalid path", http.StatusBadRequest)
+//		return
+//	}
+//	http.ServeFile(w, r, filePath)
+func SecureJoin(root, untrusted string, opts ...RootOption) (string, error) {
+	rel, err := cleanUntrusted(untrusted, opts)
+	if err != nil {
+		return "", err
+	}
+	root = filepath.Clean(root)
+
+	var resolved []string
+	pending := splitPath(rel)
+	links := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+
+		candidate := filepath.Join(root, filepath.Join(resolved...), part)
+		// Missing or inaccessible components cannot be symbolic links to follow.
+		info, err := os.Lstat(candidate)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("failed to resolve %q: too many symbolic links", untrusted)
+		}
+		target, err := os.Readlink(candidate)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			// Absolute targets are relative to the root.
+			resolved = resolved[:0]
+			target = strings.TrimPrefix(target, filepath.VolumeName(target))
+		}
+		pending = append(splitPath(target), pending...)
+	}
+	return filepath.Join(root, filepath.Join(resolved...)), nil
+}
+
+// splitPath splits a path on slashes and the OS separator.
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == filepath.Separator
+	})
+}
+
+// RootDir gives access to the files of a directory, refusing names that escape it through
+// "..", absolute paths or symbolic links, even if the tree is modified concurrently.
+// It is built on os.Root, and accepts untrusted names like SecureJoin does, except that
+// symbolic links escaping the directory are refused rather than resolved within it.
+type RootDir struct {
+	root *os.Root
+	opts []RootOption
+}
+
+// OpenRootDir opens the directory dir as a RootDir. Close it when done.
+//
+// Example:
+//
+//	uploads, err := OpenRootDir("/var/lib/app/uploads")
+//	if err != nil {
+//		return err
+//	}
+//	defer uploads.Close()
+//	f, err := uploads.Create(r.URL.Query().Get("name"))
+func OpenRootDir(dir string, opts ...RootOption) (*RootDir, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &RootDir{root: root, opts: opts}, nil
+}
+
+// Name returns the path of the directory.
+func (r *RootDir) Name() string {
+	return r.root.Name()
+}
+
+// Close closes the directory.
+func (r *RootDir) Close() error {
+	return r.root.Close()
+}
+
+// FS returns a file system for the directory.
+func (r *RootDir) FS() fs.FS {
+	return r.root.FS()
+}
+
+// name validates an untrusted name.
+func (r *RootDir) name(untrusted string) (string, error) {
+	rel, err := cleanUntrusted(untrusted, r.opts)
+	if err != nil {
+		return "", err
+	}
+	if rel == "" {
+		return ".", nil
+	}
+	return rel, nil
+}
+
+// Open opens the named file for reading.
+func (r *RootDir) Open(name string) (*os.File, error) {
+	rel, err := r.name(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Open(rel)
+}
+
+// Create creates or truncates the named file, with permissions 0666 before umask.
+func (r *RootDir) Create(name string) (*os.File, error) {
+	return r.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens the named file with the flags and permissions of os.OpenFile.
+func (r *RootDir) OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	rel, err := r.name(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.OpenFile(rel, flag, perm)
+}
+
+// ReadFile returns the content of the named file.
+func (r *RootDir) ReadFile(name string) ([]byte, error) {
+	rel, err := r.name(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(r.root.FS(), filepath.ToSlash(rel))
+}
+
+// WriteFile writes data to the named file, creating it with perm if needed.
+func (r *RootDir) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := r.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Stat returns the file information of the named file, following symbolic links inside the directory.
+func (r *RootDir) Stat(name string) (fs.FileInfo, error) {
+	rel, err := r.name(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.root.Stat(rel)
+}
+
+// Mkdir creates the named directory.
+func (r *RootDir) Mkdir(name string, perm fs.FileMode) error {
+	rel, err := r.name(name)
+	if err != nil {
+		return err
+	}
+	return r.root.Mkdir(rel, perm)
+}
+
+// MkdirAll creates the named directory and its missing parents.
+func (r *RootDir) MkdirAll(name string, perm fs.FileMode) error {
+	rel, err := r.name(name)
+	if err != nil {
+		return err
+	}
+	p := ""
+	for _, part := range splitPath(rel) {
+		p = filepath.Join(p, part)
+		err := r.root.Mkdir(p, perm)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		if info, statErr := r.root.Stat(p); statErr != nil || !info.IsDir() {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (r *RootDir) Remove(name string) error {
+	rel, err := r.name(name)
+	if err != nil {
+		return err
+	}
+	return r.root.Remove(rel)
+}
@@ -0,0 +1,102 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecureJoin(t *testing.T) {
+	root := t.TempDir()
+	createTestTree(t, root, map[string]string{"public/index.html": "index"})
+	links := map[string]string{
+		"up":       "../../..",
+		"absolute": "/etc",
+		"docs":     "public",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("Symbolic links are not supported: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		"public/index.html":      "public/index.html",
+		"/public/index.html":     "public/index.html",
+		"../../etc/passwd":       "etc/passwd",
+		"public/../../../secret": "secret",
+		"up/etc/passwd":          "etc/passwd",
+		"absolute/passwd":        "etc/passwd",
+		"docs/index.html":        "public/index.html",
+		"docs/../docs/../x":      "x",
+		"":                       "",
+	}
+	for input, expected := range tests {
+		got, err := SecureJoin(root, input)
+		if err != nil {
+			t.Errorf("SecureJoin(%q): unexpected error: %v", input, err)
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(expected)); got != want {
+			t.Errorf("SecureJoin(%q) = %q, expected %q", input, got, want)
+		}
+	}
+
+	if _, err := SecureJoin(root, "index\x00.html"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath for a NUL byte, got %v", err)
+	}
+	if _, err := SecureJoin(root, "/etc/passwd", WithRejectAbsolute()); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath for an absolute path, got %v", err)
+	}
+
+	if err := os.Symlink("loop", filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SecureJoin(root, "loop/file"); err == nil {
+		t.Errorf("Expected an error for a symbolic link loop")
+	}
+}
+
+func TestRootDir(t *testing.T) {
+	base := t.TempDir()
+	writeTestFile(t, base, "secret.txt", []byte("secret"))
+	dir := filepath.Join(base, "root")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+
+	root, err := OpenRootDir(dir)
+	if err != nil {
+		t.Fatalf("Error opening root: %v", err)
+	}
+	defer root.Close()
+
+	if err := root.MkdirAll("/uploads/2024", 0755); err != nil {
+		t.Fatalf("Error creating directories: %v", err)
+	}
+	if err := root.WriteFile("uploads/2024/a.txt", []byte("a"), 0644); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	if data, err := root.ReadFile("/uploads/../uploads/2024/a.txt"); err != nil || string(data) != "a" {
+		t.Errorf("Unexpected content %q (%v)", data, err)
+	}
+	if _, err := root.Open("../secret.txt"); err == nil {
+		t.Errorf("Expected the file outside the root to be unreachable")
+	}
+	if _, err := root.Open("escape"); err == nil {
+		t.Errorf("Expected the escaping symbolic link to be refused")
+	}
+	if _, err := root.Create("uploads/\x00"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath, got %v", err)
+	}
+	if err := root.Remove("uploads/2024/a.txt"); err != nil {
+		t.Errorf("Error removing file: %v", err)
+	}
+	if FileExists(filepath.Join(dir, "uploads", "2024", "a.txt")) {
+		t.Errorf("Expected the file to be removed")
+	}
+}
@@ -0,0 +1,206 @@
+package goutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// TLSOption configures the *tls.Config built by NewTLSConfig.
+type TLSOption func(*tlsOptions)
+
+// tlsOptions holds the settings collected from TLSOption values.
+type tlsOptions struct {
+	certFile               string
+	keyFile                string
+	keyPassphrase          string
+	caFiles                []string
+	clientAuth             tls.ClientAuthType
+	clientAuthSet          bool
+	minVersion             uint16
+	maxVersion             uint16
+	cipherSuites           []uint16
+	curvePreferences       []tls.CurveID
+	nextProtos             []string
+	sessionTicketsDisabled bool
+	store                  *CertificateStore
+	revocationChecker      *RevocationChecker
+}
+
+// WithCertificate sets the server certificate and private key.
+// Both accept any source supported by LoadPEM: a file path, inline or base64-encoded PEM,
+// or an "env:NAME" reference.
+func WithCertificate(certFile, keyFile string) TLSOption {
+	return func(o *tlsOptions) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithClientCAs adds one or more CA certificate sources (see LoadPEM) used to verify client certificates.
+// Client certificates are verified if given unless WithClientAuth requests otherwise.
+func WithClientCAs(caFiles ...string) TLSOption {
+	return func(o *tlsOptions) {
+		o.caFiles = append(o.caFiles, caFiles...)
+	}
+}
+
+// WithClientAuth sets the policy for client certificate authentication.
+func WithClientAuth(authType tls.ClientAuthType) TLSOption {
+	return func(o *tlsOptions) {
+		o.clientAuth = authType
+		o.clientAuthSet = true
+	}
+}
+
+// WithMinVersion sets the minimum TLS version (e.g., tls.VersionTLS12). Defaults to TLS 1.2.
+func WithMinVersion(version uint16) TLSOption {
+	return func(o *tlsOptions) {
+		o.minVersion = version
+	}
+}
+
+// WithMaxVersion sets the maximum TLS version (e.g., tls.VersionTLS13).
+func WithMaxVersion(version uint16) TLSOption {
+	return func(o *tlsOptions) {
+		o.maxVersion = version
+	}
+}
+
+// WithCipherSuites restricts the TLS 1.0-1.2 cipher suites. TLS 1.3 suites are not configurable.
+func WithCipherSuites(suites ...uint16) TLSOption {
+	return func(o *tlsOptions) {
+		o.cipherSuites = append(o.cipherSuites, suites...)
+	}
+}
+
+// WithCurvePreferences sets the elliptic curves used in the key exchange, in preference order.
+func WithCurvePreferences(curves ...tls.CurveID) TLSOption {
+	return func(o *tlsOptions) {
+		o.curvePreferences = append(o.curvePreferences, curves...)
+	}
+}
+
+// WithNextProtos sets the supported ALPN protocols (e.g., "h2", "http/1.1").
+func WithNextProtos(protos ...string) TLSOption {
+	return func(o *tlsOptions) {
+		o.nextProtos = append(o.nextProtos, protos...)
+	}
+}
+
+// WithSessionTicketsDisabled disables TLS session ticket resumption.
+func WithSessionTicketsDisabled(disabled bool) TLSOption {
+	return func(o *tlsOptions) {
+		o.sessionTicketsDisabled = disabled
+	}
+}
+
+// NewTLSConfig creates a TLS configuration from functional options.
+//
+// Example:
+//
+//	config, err := NewTLSConfig(
+//		WithCertificate("server.crt", "server.key"),
+//		WithClientCAs("ca.crt"),
+//		WithClientAuth(tls.RequireAndVerifyClientCert),
+//		WithNextProtos("h2", "http/1.1"),
+//	)
+func NewTLSConfig(opts ...TLSOption) (*tls.Config, error) {
+	o := &tlsOptions{minVersion: tls.VersionTLS12}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:             o.minVersion,
+		MaxVersion:             o.maxVersion,
+		CipherSuites:           o.cipherSuites,
+		CurvePreferences:       o.curvePreferences,
+		NextProtos:             o.nextProtos,
+		SessionTicketsDisabled: o.sessionTicketsDisabled,
+	}
+	if o.certFile != "" {
+		cert, err := LoadKeyPair(o.certFile, o.keyFile, o.keyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if o.store != nil {
+		config.GetCertificate = o.store.GetCertificate
+	}
+
+	if len(o.caFiles) > 0 {
+		pool, err := loadCertPool(o.caFiles...)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if o.clientAuthSet {
+		config.ClientAuth = o.clientAuth
+	}
+	if o.revocationChecker != nil {
+		config.VerifyConnection = o.revocationChecker.VerifyConnection
+	}
+	return config, nil
+}
+
+// validate checks the collected options for inconsistencies.
+func (o *tlsOptions) validate() error {
+	if (o.certFile == "") != (o.keyFile == "") {
+		return errors.New("both certificate and key must be set")
+	}
+	if o.certFile == "" && o.store == nil {
+		return errors.New("a certificate or a certificate store is required")
+	}
+	if o.maxVersion != 0 && o.minVersion > o.maxVersion {
+		return fmt.Errorf("minimum TLS version %s is greater than maximum TLS version %s",
+			tls.VersionName(o.minVersion), tls.VersionName(o.maxVersion))
+	}
+	for _, id := range o.cipherSuites {
+		if !isKnownCipherSuite(id) {
+			return fmt.Errorf("unsupported cipher suite: 0x%04x", id)
+		}
+	}
+	if o.clientAuth >= tls.VerifyClientCertIfGiven && len(o.caFiles) == 0 {
+		return errors.New("client certificate verification requires at least one CA")
+	}
+	return nil
+}
+
+// isKnownCipherSuite reports whether the cipher suite is implemented by crypto/tls.
+func isKnownCipherSuite(id uint16) bool {
+	for _, s := range tls.CipherSuites() {
+		if s.ID == id {
+			return true
+		}
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCertPool reads the PEM sources into a certificate pool.
+// It returns an error if any source cannot be read or contains no valid certificates.
+func loadCertPool(sources ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for i, source := range sources {
+		data, err := LoadPEM(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CA %d: %w", i+1, err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to parse CA certificates from CA %d", i+1)
+		}
+	}
+	return pool, nil
+}
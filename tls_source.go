@@ -0,0 +1,273 @@
+package goutils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo structure (RFC 5208).
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params is the PBES2-params structure (RFC 8018).
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params is the PBKDF2-params structure (RFC 8018).
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// LoadPEM resolves a PEM source and returns its content.
+//
+// The source can be:
+//   - "env:NAME": the value of the environment variable NAME, as inline or base64-encoded PEM
+//   - an inline PEM string (containing "-----BEGIN")
+//   - base64-encoded PEM
+//   - a file path
+//
+// Values from the environment and multi-line sources are never read as file paths, so
+// errors about a malformed secret name the variable rather than including its value.
+func LoadPEM(source string) ([]byte, error) {
+	name, fromEnv := strings.CutPrefix(source, "env:")
+	if fromEnv {
+		value, exists := os.LookupEnv(name)
+		if !exists || value == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		source = value
+	}
+	if data, ok := decodeInlinePEM(source); ok {
+		return data, nil
+	}
+	if fromEnv {
+		return nil, fmt.Errorf("environment variable %s does not contain PEM or base64-encoded PEM", name)
+	}
+	if strings.ContainsAny(source, "\r\n") {
+		return nil, errors.New("inline PEM source is not valid PEM or base64-encoded PEM")
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PEM file: %w", err)
+	}
+	return data, nil
+}
+
+// decodeInlinePEM returns the content of an inline or base64-encoded PEM source.
+func decodeInlinePEM(source string) ([]byte, bool) {
+	if strings.Contains(source, "-----BEGIN") {
+		return []byte(source), true
+	}
+	if compact := strings.Join(strings.Fields(source), ""); IsBase64(compact) {
+		if decoded, err := base64.StdEncoding.DecodeString(compact); err == nil && bytes.Contains(decoded, []byte("-----BEGIN")) {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+// isPEMFile reports whether LoadPEM reads the source from a file.
+func isPEMFile(source string) bool {
+	if strings.HasPrefix(source, "env:") || strings.ContainsAny(source, "\r\n") {
+		return false
+	}
+	_, inline := decodeInlinePEM(source)
+	return !inline
+}
+
+// pemSourceName describes a PEM source in error messages without revealing inline content.
+func pemSourceName(source string) string {
+	if strings.HasPrefix(source, "env:") || isPEMFile(source) {
+		return source
+	}
+	return "inline PEM"
+}
+
+// LoadKeyPair loads a certificate and private key from PEM sources (see LoadPEM).
+// Encrypted PKCS#8 keys ("ENCRYPTED PRIVATE KEY") are decrypted with passphrase.
+func LoadKeyPair(certSource, keySource, passphrase string) (tls.Certificate, error) {
+	certPEM, err := LoadPEM(certSource)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	keyPEM, err := LoadPEM(keySource)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load private key: %w", err)
+	}
+	if keyPEM, err = decryptPrivateKeyPEM(keyPEM, passphrase); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// DecryptPKCS8PrivateKey decrypts a DER-encoded PKCS#8 EncryptedPrivateKeyInfo using PBES2
+// (PBKDF2 with HMAC-SHA1/SHA-256/SHA-384/SHA-512 and AES-CBC or 3DES-CBC) and parses the key.
+func DecryptPKCS8PrivateKey(der []byte, passphrase string) (any, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted private key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption algorithm: %s", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters: %w", err)
+	}
+
+	block, iv, err := pbes2Cipher(params, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted private key length")
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+
+	plain, err = unpadPKCS7(plain, block.BlockSize())
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key: incorrect passphrase")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key: incorrect passphrase")
+	}
+	return key, nil
+}
+
+// pbes2Cipher derives the key from the passphrase and returns the block cipher and IV.
+func pbes2Cipher(params pbes2Params, passphrase string) (cipher.Block, []byte, error) {
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("unsupported key derivation function: %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse PBKDF2 parameters: %w", err)
+	}
+
+	var newHash func() hash.Hash
+	switch prf := kdf.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+		newHash = sha1.New
+	case prf.Equal(oidHMACWithSHA256):
+		newHash = sha256.New
+	case prf.Equal(oidHMACWithSHA384):
+		newHash = sha512.New384
+	case prf.Equal(oidHMACWithSHA512):
+		newHash = sha512.New
+	default:
+		return nil, nil, fmt.Errorf("unsupported PBKDF2 pseudo-random function: %s", prf)
+	}
+
+	var keyLength int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, nil, fmt.Errorf("unsupported encryption scheme: %s", scheme)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse encryption IV: %w", err)
+	}
+	key, err := pbkdf2.Key(newHash, passphrase, kdf.Salt, kdf.IterationCount, keyLength)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("invalid encryption IV length")
+	}
+	return block, iv, nil
+}
+
+// decryptPrivateKeyPEM replaces an "ENCRYPTED PRIVATE KEY" block with its decrypted
+// "PRIVATE KEY" equivalent. Other PEM data is returned unchanged.
+func decryptPrivateKeyPEM(keyPEM []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+		return keyPEM, nil
+	}
+	if passphrase == "" {
+		return nil, errors.New("private key is encrypted but no passphrase was provided")
+	}
+	key, err := DecryptPKCS8PrivateKey(block.Bytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// unpadPKCS7 removes and validates PKCS#7 padding.
+func unpadPKCS7(data []byte, blockSize int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// WithKeyPassphrase sets the passphrase used to decrypt an encrypted PKCS#8 private key.
+func WithKeyPassphrase(passphrase string) TLSOption {
+	return func(o *tlsOptions) {
+		o.keyPassphrase = passphrase
+	}
+}
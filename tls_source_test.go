@@ -0,0 +1,176 @@
+package goutils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+// encryptTestKey encrypts a PKCS#8 private key PEM with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC).
+func encryptTestKey(t *testing.T, keyPEM []byte, passphrase string) []byte {
+	t.Helper()
+	block, _ := pem.Decode(keyPEM)
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, 2048, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(block.Bytes)%aes.BlockSize
+	plain := append(block.Bytes, make([]byte, padding)...)
+	for i := len(block.Bytes); i < len(plain); i++ {
+		plain[i] = byte(padding)
+	}
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(c, iv).CryptBlocks(encrypted, plain)
+
+	mustMarshal := func(v any) asn1.RawValue {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{FullBytes: der}
+	}
+	params := pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm: oidPBKDF2,
+			Parameters: mustMarshal(pbkdf2Params{
+				Salt:           salt,
+				IterationCount: 2048,
+				PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+			}),
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: mustMarshal(iv)},
+	}
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: mustMarshal(params)},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+}
+
+func TestLoadPEM(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	file := writeTestFile(t, dir, "ca.crt", ca.certPEM)
+	t.Setenv("TEST_TLS_CA", base64.StdEncoding.EncodeToString(ca.certPEM))
+	t.Setenv("TEST_TLS_CA_FILE", file)
+	t.Setenv("TEST_TLS_TRUNCATED", "MIIBszCCAVmgAwIBAgIU-secret")
+
+	sources := []string{
+		file,
+		string(ca.certPEM),
+		base64.StdEncoding.EncodeToString(ca.certPEM),
+		"env:TEST_TLS_CA",
+	}
+	for _, source := range sources {
+		data, err := LoadPEM(source)
+		if err != nil {
+			t.Errorf("Error loading %q: %v", TruncateText(source, 20), err)
+			continue
+		}
+		if string(data) != string(ca.certPEM) {
+			t.Errorf("Unexpected content for %q", TruncateText(source, 20))
+		}
+	}
+	if _, err := LoadPEM("env:TEST_TLS_MISSING"); err == nil {
+		t.Errorf("Expected an error for a missing environment variable")
+	}
+	if _, err := LoadPEM(dir + "/missing.crt"); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+
+	// Environment values and multi-line sources are not read as file paths, and their
+	// content is not included in the error.
+	for _, source := range []string{"env:TEST_TLS_CA_FILE", "env:TEST_TLS_TRUNCATED", "MIIBszCCAVmgAwIBAgIU\n-secret"} {
+		_, err := LoadPEM(source)
+		if err == nil {
+			t.Errorf("Expected an error for %q", source)
+			continue
+		}
+		if strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), file) {
+			t.Errorf("Expected the error to hide the value, got %v", err)
+		}
+		if name, ok := strings.CutPrefix(source, "env:"); ok && !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the error to name %s, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadKeyPairEncrypted(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "api", "api.example.com")
+	encrypted := encryptTestKey(t, keyPEM, "s3cret")
+	t.Setenv("TEST_TLS_KEY", string(encrypted))
+
+	if _, err := LoadKeyPair(string(certPEM), "env:TEST_TLS_KEY", "s3cret"); err != nil {
+		t.Fatalf("Error loading encrypted key pair: %v", err)
+	}
+	if _, err := LoadKeyPair(string(certPEM), "env:TEST_TLS_KEY", "wrong"); err == nil {
+		t.Errorf("Expected an error for a wrong passphrase")
+	}
+	if _, err := LoadKeyPair(string(certPEM), "env:TEST_TLS_KEY", ""); err == nil {
+		t.Errorf("Expected an error for a missing passphrase")
+	}
+
+	config, err := NewTLSConfig(
+		WithCertificate(base64.StdEncoding.EncodeToString(certPEM), "env:TEST_TLS_KEY"),
+		WithKeyPassphrase("s3cret"),
+		WithClientCAs(string(ca.certPEM)),
+	)
+	if err != nil {
+		t.Fatalf("Error creating TLS config: %v", err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Errorf("Expected one certificate, got %d", len(config.Certificates))
+	}
+}
+
+func TestLoadTLSConfigSources(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "api", "api.example.com")
+	t.Setenv("TEST_TLS_CERT", base64.StdEncoding.EncodeToString(certPEM))
+	t.Setenv("TEST_TLS_KEY", string(keyPEM))
+
+	config, err := LoadTLSConfig("env:TEST_TLS_CERT", "env:TEST_TLS_KEY", string(ca.certPEM), true)
+	if err != nil {
+		t.Fatalf("Error loading TLS config: %v", err)
+	}
+	if config.ClientCAs == nil || config.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("Expected client certificates to be verified")
+	}
+	caFile := writeTestFile(t, t.TempDir(), "ca.crt", []byte("not a certificate"))
+	if _, err := LoadTLSConfig("env:TEST_TLS_CERT", "env:TEST_TLS_KEY", caFile, true); err == nil {
+		t.Errorf("Expected an error for an invalid CA")
+	}
+
+	store, err := NewCertificateStore(CertificatePair{CertFile: "env:TEST_TLS_CERT", KeyFile: "env:TEST_TLS_KEY"})
+	if err != nil {
+		t.Fatalf("Error creating certificate store: %v", err)
+	}
+	if cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"}); err != nil || cert.Leaf.Subject.CommonName != "api" {
+		t.Errorf("Unexpected certificate (%v)", err)
+	}
+}
@@ -0,0 +1,290 @@
+package goutils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertificatePair is the location of a certificate and its private key, as PEM sources
+// (file paths, inline or base64-encoded PEM, or "env:NAME", see LoadPEM). Watch only
+// watches the sources that are files.
+type CertificatePair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// CertificateStore holds multiple certificates indexed by their subject alternative names
+// and selects one per connection from the SNI server name.
+//
+// It is safe for concurrent use, and certificates can be reloaded while serving.
+type CertificateStore struct {
+	mu          sync.RWMutex
+	dir         string
+	pairs       []CertificatePair
+	defaultName string
+	names       map[string]*tls.Certificate
+	wildcards   map[string]*tls.Certificate
+	defaultCert *tls.Certificate
+}
+
+// certificateExtensions lists the file extensions recognised as certificates in a store directory.
+var certificateExtensions = []string{".crt", ".cert", ".pem"}
+
+// NewCertificateStore loads the given certificate/key pairs into a new store.
+// The first pair is used as the default certificate until SetDefault is called.
+func NewCertificateStore(pairs ...CertificatePair) (*CertificateStore, error) {
+	s := &CertificateStore{pairs: pairs}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadCertificateStore loads every certificate/key pair found in dir into a new store.
+//
+// A certificate file ("name.crt", "name.cert" or "name.pem") is paired with "name.key"
+// or "name-key.pem". Certificates without a matching key are ignored.
+func LoadCertificateStore(dir string) (*CertificateStore, error) {
+	s := &CertificateStore{dir: dir}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate returns the certificate matching the SNI server name of the client hello,
+// falling back to the default certificate. It is meant to be used as tls.Config.GetCertificate.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if cert := s.lookup(hello.ServerName); cert != nil {
+		return cert, nil
+	}
+	if s.defaultCert != nil {
+		return s.defaultCert, nil
+	}
+	return nil, fmt.Errorf("no certificate found for server name %q", hello.ServerName)
+}
+
+// SetDefault selects the certificate serving serverName as the default certificate,
+// used when the client sends no SNI or an unknown name.
+func (s *CertificateStore) SetDefault(serverName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cert := s.lookup(serverName)
+	if cert == nil {
+		return fmt.Errorf("no certificate found for server name %q", serverName)
+	}
+	s.defaultName = serverName
+	s.defaultCert = cert
+	return nil
+}
+
+// Names returns the sorted list of names served by the store.
+func (s *CertificateStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.names)+len(s.wildcards))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	for name := range s.wildcards {
+		names = append(names, "*."+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload re-reads all certificates from their source. On error, the previously
+// loaded certificates are kept.
+func (s *CertificateStore) Reload() error {
+	pairs := s.pairs
+	if s.dir != "" {
+		var err error
+		if pairs, err = findCertificatePairs(s.dir); err != nil {
+			return err
+		}
+	}
+	if len(pairs) == 0 {
+		return errors.New("no certificate/key pairs found")
+	}
+
+	names := make(map[string]*tls.Certificate)
+	wildcards := make(map[string]*tls.Certificate)
+	var first *tls.Certificate
+	for _, pair := range pairs {
+		cert, err := LoadKeyPair(pair.CertFile, pair.KeyFile, "")
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s: %w", pemSourceName(pair.CertFile), err)
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return fmt.Errorf("failed to parse certificate %s: %w", pemSourceName(pair.CertFile), err)
+			}
+		}
+		if first == nil {
+			first = &cert
+		}
+		for _, name := range certificateNames(cert.Leaf) {
+			index := names
+			if strings.HasPrefix(name, "*.") {
+				index, name = wildcards, name[2:]
+			}
+			// Prefer the certificate that expires last, which eases rotation.
+			if existing, ok := index[name]; !ok || cert.Leaf.NotAfter.After(existing.Leaf.NotAfter) {
+				index[name] = &cert
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = names
+	s.wildcards = wildcards
+	s.defaultCert = first
+	if s.defaultName != "" {
+		if cert := s.lookup(s.defaultName); cert != nil {
+			s.defaultCert = cert
+		}
+	}
+	return nil
+}
+
+// Watch reloads the store when the certificate files change, until ctx is canceled.
+// Changes are detected with a Watcher, so Kubernetes Secret updates are picked up;
+// interval is the polling interval on platforms without native notifications, with
+// the Watcher default when zero or negative. Watch and reload errors are passed to
+// onError, which may be nil.
+func (s *CertificateStore) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+	var opts []WatchOption
+	if interval > 0 {
+		opts = append(opts, WithPollInterval(interval))
+	}
+	w, err := NewWatcher(ctx, opts...)
+	if err != nil {
+		report(err)
+		return
+	}
+	defer func(w *Watcher) {
+		_ = w.Close()
+	}(w)
+
+	paths := []string{s.dir}
+	if s.dir == "" {
+		paths = paths[:0]
+		for _, pair := range s.pairs {
+			for _, source := range []string{pair.CertFile, pair.KeyFile} {
+				if isPEMFile(source) {
+					paths = append(paths, source)
+				}
+			}
+		}
+	}
+	for _, p := range paths {
+		if err := w.Add(p); err != nil {
+			report(err)
+		}
+	}
+
+	for {
+		select {
+		case _, ok := <-w.Events():
+			if !ok {
+				return
+			}
+			if err := s.Reload(); err != nil {
+				report(err)
+			}
+		case err, ok := <-w.Errors():
+			if ok {
+				report(err)
+			}
+		}
+	}
+}
+
+// lookup finds the certificate for serverName, trying an exact match before a wildcard match.
+// The caller must hold the lock.
+func (s *CertificateStore) lookup(serverName string) *tls.Certificate {
+	name := strings.TrimSuffix(strings.ToLower(serverName), ".")
+	if name == "" {
+		return nil
+	}
+	if cert, ok := s.names[name]; ok {
+		return cert
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := s.wildcards[name[i+1:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+// certificateNames returns the lowercase DNS names of the certificate,
+// falling back to the common name when it has no DNS SANs.
+func certificateNames(cert *x509.Certificate) []string {
+	names := cert.DNSNames
+	if len(names) == 0 && cert.Subject.CommonName != "" {
+		names = []string{cert.Subject.CommonName}
+	}
+	result := make([]string, 0, len(names)+len(cert.IPAddresses))
+	for _, name := range names {
+		result = append(result, strings.TrimSuffix(strings.ToLower(name), "."))
+	}
+	for _, ip := range cert.IPAddresses {
+		result = append(result, ip.String())
+	}
+	return result
+}
+
+// findCertificatePairs scans dir for certificate files with a matching key file.
+func findCertificatePairs(dir string) ([]CertificatePair, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate directory %s: %w", dir, err)
+	}
+	var pairs []CertificatePair
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		if entry.IsDir() || !slices.Contains(certificateExtensions, ext) || strings.HasSuffix(base, "-key") {
+			continue
+		}
+		for _, keyName := range []string{base + ".key", base + "-key.pem"} {
+			keyFile := filepath.Join(dir, keyName)
+			if FileExists(keyFile) {
+				pairs = append(pairs, CertificatePair{CertFile: filepath.Join(dir, name), KeyFile: keyFile})
+				break
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// WithCertificateStore serves certificates from the store, selected by SNI.
+// It can be used instead of, or in addition to, WithCertificate.
+func WithCertificateStore(store *CertificateStore) TLSOption {
+	return func(o *tlsOptions) {
+		o.store = store
+	}
+}
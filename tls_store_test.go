@@ -0,0 +1,114 @@
+package goutils
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestCertificateStore(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "api", "api.example.com")
+	writeTestFile(t, dir, "api.crt", certPEM)
+	writeTestFile(t, dir, "api.key", keyPEM)
+	certPEM, keyPEM = ca.issue(t, "wildcard", "*.apps.example.com")
+	writeTestFile(t, dir, "apps.pem", certPEM)
+	writeTestFile(t, dir, "apps-key.pem", keyPEM)
+	certPEM, _ = ca.issue(t, "orphan", "orphan.example.com")
+	writeTestFile(t, dir, "orphan.crt", certPEM)
+
+	store, err := LoadCertificateStore(dir)
+	if err != nil {
+		t.Fatalf("Error loading certificate store: %v", err)
+	}
+	if names := store.Names(); len(names) != 2 {
+		t.Fatalf("Expected 2 names, got %v", names)
+	}
+
+	tests := []struct {
+		serverName string
+		expected   string
+	}{
+		{"api.example.com", "api"},
+		{"API.Example.com.", "api"},
+		{"web.apps.example.com", "wildcard"},
+		{"unknown.example.com", "api"},
+		{"", "api"},
+	}
+	if err := store.SetDefault("api.example.com"); err != nil {
+		t.Fatalf("Error setting default: %v", err)
+	}
+	for _, test := range tests {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+		if err != nil {
+			t.Fatalf("Error getting certificate for %q: %v", test.serverName, err)
+		}
+		if cert.Leaf.Subject.CommonName != test.expected {
+			t.Errorf("For %q, expected %s, got %s", test.serverName, test.expected, cert.Leaf.Subject.CommonName)
+		}
+	}
+	if err := store.SetDefault("missing.example.com"); err == nil {
+		t.Errorf("Expected an error for an unknown default")
+	}
+
+	config, err := NewTLSConfig(WithCertificateStore(store))
+	if err != nil {
+		t.Fatalf("Error creating TLS config: %v", err)
+	}
+	if config.GetCertificate == nil {
+		t.Errorf("Expected GetCertificate to be set")
+	}
+}
+
+func TestCertificateStoreWatch(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "old", "old.example.com")
+	certFile := writeTestFile(t, dir, "site.crt", certPEM)
+	keyFile := writeTestFile(t, dir, "site.key", keyPEM)
+
+	store, err := NewCertificateStore(CertificatePair{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Error creating certificate store: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Watch(ctx, 10*time.Millisecond, func(err error) { t.Logf("Reload error: %v", err) })
+
+	time.Sleep(20 * time.Millisecond)
+	certPEM, keyPEM = ca.issue(t, "new", "new.example.com")
+	writeTestFile(t, dir, "site.key", keyPEM)
+	writeTestFile(t, dir, "site.crt", certPEM)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "new.example.com"})
+		if err == nil && cert.Leaf.Subject.CommonName == "new" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Certificate store was not reloaded")
+}
+
+func TestCertificateStoreWatchDefaultInterval(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "site", "site.example.com")
+	store, err := NewCertificateStore(CertificatePair{
+		CertFile: writeTestFile(t, dir, "site.crt", certPEM),
+		KeyFile:  writeTestFile(t, dir, "site.key", keyPEM),
+	})
+	if err != nil {
+		t.Fatalf("Error creating certificate store: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var watchErr error
+	store.Watch(ctx, 0, func(err error) { watchErr = err })
+	if watchErr != nil {
+		t.Errorf("Expected a zero interval to use the default, got %v", watchErr)
+	}
+}
@@ -0,0 +1,154 @@
+package goutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a self-signed certificate authority used to issue test certificates.
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+// newTestCA creates a self-signed CA certificate.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing CA certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a leaf certificate signed by the CA and returns its certificate and key PEM.
+func (ca *testCA) issue(t *testing.T, commonName string, dnsNames ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Error generating serial: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshalling key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestFile writes data to name inside dir and returns the full path.
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Error writing %s: %v", path, err)
+	}
+	return path
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", "localhost")
+	certFile := writeTestFile(t, dir, "server.crt", certPEM)
+	keyFile := writeTestFile(t, dir, "server.key", keyPEM)
+	caFile := writeTestFile(t, dir, "ca.crt", ca.certPEM)
+
+	config, err := NewTLSConfig(
+		WithCertificate(certFile, keyFile),
+		WithClientCAs(caFile),
+		WithClientAuth(tls.RequireAndVerifyClientCert),
+		WithMinVersion(tls.VersionTLS12),
+		WithMaxVersion(tls.VersionTLS13),
+		WithCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256),
+		WithCurvePreferences(tls.X25519, tls.CurveP256),
+		WithNextProtos("h2", "http/1.1"),
+		WithSessionTicketsDisabled(true),
+	)
+	if err != nil {
+		t.Fatalf("Error creating TLS config: %v", err)
+	}
+	if config.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("Expected RequireAndVerifyClientCert, got %v", config.ClientAuth)
+	}
+	if config.ClientCAs == nil {
+		t.Errorf("Expected client CAs to be set")
+	}
+	if config.MaxVersion != tls.VersionTLS13 || !config.SessionTicketsDisabled {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if len(config.NextProtos) != 2 {
+		t.Errorf("Expected 2 ALPN protocols, got %v", config.NextProtos)
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", "localhost")
+	certFile := writeTestFile(t, dir, "server.crt", certPEM)
+	keyFile := writeTestFile(t, dir, "server.key", keyPEM)
+	invalidCA := writeTestFile(t, dir, "invalid-ca.crt", []byte("not a certificate"))
+
+	tests := []struct {
+		name string
+		opts []TLSOption
+	}{
+		{"missing certificate", nil},
+		{"invalid CA", []TLSOption{WithCertificate(certFile, keyFile), WithClientCAs(invalidCA)}},
+		{"missing CA file", []TLSOption{WithCertificate(certFile, keyFile), WithClientCAs(filepath.Join(dir, "missing.crt"))}},
+		{"version range", []TLSOption{WithCertificate(certFile, keyFile), WithMinVersion(tls.VersionTLS13), WithMaxVersion(tls.VersionTLS12)}},
+		{"unknown cipher suite", []TLSOption{WithCertificate(certFile, keyFile), WithCipherSuites(0xffff)}},
+		{"verify without CA", []TLSOption{WithCertificate(certFile, keyFile), WithClientAuth(tls.RequireAndVerifyClientCert)}},
+	}
+	for _, test := range tests {
+		if _, err := NewTLSConfig(test.opts...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
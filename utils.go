@@ -3,7 +3,6 @@ package goutils
 import (
 	"crypto/rand"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -273,38 +272,32 @@ func GetBoolEnv(key string, defaultValue bool) bool {
 
 // SetEnv sets the value of an environment variable if the value is not empty
 func SetEnv(name, value string) error {
-	if len(value) != 0 {
-		return os.Setenv(name, value)
-	}
-	return nil
+	return SetEnvIn(osEnv, name, value)
 }
 
 // Env retrieves the value of the environment variable named by the key.
+//
+// When the variable is unset, the value is read from the file referenced by <key>_FILE,
+// if any (see ResolveEnv); when both are set, the variable wins, with a warning.
+// Errors reading the file return defaultValue, or panic in strict mode.
 func Env(envName string, defaultValue string) string {
-	if value, exists := os.LookupEnv(envName); exists {
-		return value
-	}
-	return defaultValue
+	return EnvFrom(osEnv, envName, defaultValue)
 }
 
 // EnvInt retrieves the integer value of the environment variable named by the key.
+//
+// A malformed value returns defaultValue, or panics in strict mode (see SetEnvStrict).
+// Use EnvIntE to get the error instead.
 func EnvInt(envName string, defaultValue int) int {
-	if value, exists := os.LookupEnv(envName); exists {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
+	return EnvIntFrom(osEnv, envName, defaultValue)
 }
 
 // EnvBool retrieves the boolean value of the environment variable named by the key.
+//
+// A malformed value returns defaultValue, or panics in strict mode (see SetEnvStrict).
+// Use EnvBoolE to get the error instead.
 func EnvBool(envName string, defaultValue bool) bool {
-	if value, exists := os.LookupEnv(envName); exists {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
-		}
-	}
-	return defaultValue
+	return EnvBoolFrom(osEnv, envName, defaultValue)
 }
 
 // MergeSlices merges two slices of strings
@@ -314,7 +307,8 @@ func MergeSlices(slice1, slice2 []string) []string {
 
 // ParseURLPath removes duplicated [//]
 //
-// Ensures the path starts with a single leading slash
+// Ensures the path starts with a single leading slash.
+// The result is not safe to join to a directory: use SecureJoin for that.
 func ParseURLPath(urlPath string) string {
 	// Replace any double slashes with a single slash
 	urlPath = strings.ReplaceAll(urlPath, "//", "/")
@@ -445,48 +439,14 @@ func ParseStringRange(rs string) ([]int, error) {
 }
 
 // CopyFile copies a file from the source to the destination
+//
+// Use CopyFileWithOptions to preserve metadata, refuse overwriting or copy atomically.
 func CopyFile(src, dst string) error {
-	// Open the source file for reading
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %v", err)
-	}
-	defer func(sourceFile *os.File) {
-		err := sourceFile.Close()
-		if err != nil {
-			return
-		}
-	}(sourceFile)
-
-	// Create the destination file
-	destinationFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %v", err)
-	}
-	defer func(destinationFile *os.File) {
-		err := destinationFile.Close()
-		if err != nil {
-			return
-
-		}
-	}(destinationFile)
-
-	// Copy the content from source to destination
-	_, err = io.Copy(destinationFile, sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to copy file: %v", err)
-	}
-
-	// Flush the buffer to ensure all data is written
-	err = destinationFile.Sync()
-	if err != nil {
-		return fmt.Errorf("failed to sync destination file: %v", err)
-	}
-
-	return nil
+	return CopyFileWithOptions(src, dst)
 }
 
-// ChangePermission changes the permission of the file
+// ChangePermission changes the permission of the file. The mode is a number, so pass an octal
+// literal such as 0755; use ChangeMode for octal strings, symbolic modes or recursive changes.
 func ChangePermission(filePath string, mod int) error {
 	if err := os.Chmod(filePath, fs.FileMode(mod)); err != nil {
 		return err
@@ -517,10 +477,11 @@ func IsDirEmpty(name string) (bool, error) {
 
 // WriteToFile writes the given content to a file at the specified filePath.
 // It returns an error if the operation fails.
+// Use WriteToFileAtomic to never leave a truncated file behind on a crash.
 func WriteToFile(filePath, content string) error {
 	// Use os.WriteFile to handle file creation, writing, and closing in one call.
 	// The file is created with read-write permissions for the user (0600).
-	err := os.WriteFile(filePath, []byte(content), 0600)
+	err := os.WriteFile(filePath, []byte(content), defaultFileMode)
 	if err != nil {
 		return fmt.Errorf("failed to write to file %s: %w", filePath, err)
 	}
@@ -588,19 +549,19 @@ func RemoveDuplicates[T comparable](elements []T) []T {
 	return result
 }
 
-// LoadTLSConfig creates a TLS configuration from certificate and key files
+// LoadTLSConfig creates a TLS configuration from certificate and key sources
 // Parameters:
-//   - certFile: Path to the certificate file (PEM format)
-//   - keyFile: Path to the private key file (PEM format)
-//   - caFile: Optional path to CA certificate file for client verification (set to "" to disable)
+//   - certFile: The certificate, as a file path, inline PEM, base64-encoded PEM or "env:NAME" (see LoadPEM)
+//   - keyFile: The private key, as a PEM source like certFile
+//   - caFile: Optional CA certificate source for client verification (set to "" to disable)
 //   - clientAuth: Whether to require client certificate verification
 //
 // Returns:
 //   - *tls.Config configured with the certificate and settings
-//   - error if any occurred during loading
+//   - error if any occurred during loading, including a CA source with no valid certificates
 func LoadTLSConfig(certFile, keyFile, caFile string, clientAuth bool) (*tls.Config, error) {
 	// Load server certificate and key
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	cert, err := LoadKeyPair(certFile, keyFile, "")
 	if err != nil {
 		return nil, err
 	}
@@ -612,16 +573,11 @@ func LoadTLSConfig(certFile, keyFile, caFile string, clientAuth bool) (*tls.Conf
 
 	// If caFile is provided, set up client certificate verification
 	if caFile != "" {
-		caCert, err := os.ReadFile(caFile)
+		caCertPool, err := loadCertPool(caFile)
 		if err != nil {
 			return nil, err
 		}
 
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			_, _ = fmt.Fprintf(defaultErrorWriter, "Warning: failed to append CA certs from PEM")
-		}
-
 		config.ClientCAs = caCertPool
 		if clientAuth {
 			config.ClientAuth = tls.RequireAndVerifyClientCert
@@ -747,12 +703,14 @@ func ReplaceEnvVars(s string) string {
 	s = replaceFunctions(s)
 
 	// Then, replace environment variables
-	s = replaceEnvVariables(s)
+	s = replaceEnvVariables(s, os.LookupEnv)
 
 	return s
 }
 
-func replaceEnvVariables(s string) string {
+// replaceEnvVariables replaces the ${VAR_NAME} and {VAR_NAME} references found by lookup,
+// keeping the others as is.
+func replaceEnvVariables(s string, lookup func(string) (string, bool)) string {
 	if !envPattern.MatchString(s) {
 		return s
 	}
@@ -764,7 +722,7 @@ func replaceEnvVariables(s string) string {
 		}
 
 		name := submatch[1]
-		if val, ok := os.LookupEnv(name); ok {
+		if val, ok := lookup(name); ok {
 			return val
 		}
 		return match
@@ -0,0 +1,610 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WatchOp is the kind of change reported by a Watcher.
+type WatchOp uint8
+
+const (
+	// WatchCreate reports a new file or directory.
+	WatchCreate WatchOp = iota + 1
+	// WatchWrite reports a content change. Replacing a file through a rename, as editors
+	// and atomic writers do, or swapping the target of a symbolic link is reported as a write.
+	WatchWrite
+	// WatchRemove reports a removed file or directory.
+	WatchRemove
+	// WatchChmod reports a permission change.
+	WatchChmod
+)
+
+// String returns the name of the operation.
+func (op WatchOp) String() string {
+	switch op {
+	case WatchCreate:
+		return "CREATE"
+	case WatchWrite:
+		return "WRITE"
+	case WatchRemove:
+		return "REMOVE"
+	case WatchChmod:
+		return "CHMOD"
+	default:
+		return fmt.Sprintf("WatchOp(%d)", uint8(op))
+	}
+}
+
+// WatchEvent is a change of a watched path.
+type WatchEvent struct {
+	Path string
+	Op   WatchOp
+}
+
+// String returns the operation followed by the path.
+func (e WatchEvent) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+// WatchOption configures a Watcher.
+type WatchOption func(*watchOptions)
+
+// watchOptions holds the settings collected from WatchOption values.
+type watchOptions struct {
+	debounce     time.Duration
+	recursive    bool
+	pollInterval time.Duration
+	polling      bool
+}
+
+// WithDebounce sets how long changes are collected before events are delivered,
+// so a burst of writes results in a single event. Defaults to 100ms; zero disables it.
+func WithDebounce(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.debounce = d
+	}
+}
+
+// WithRecursive watches the subdirectories of added directories, including the ones created later.
+// Symbolic links to directories are not followed.
+func WithRecursive() WatchOption {
+	return func(o *watchOptions) {
+		o.recursive = true
+	}
+}
+
+// WithPollInterval sets the interval of the polling fallback, used on platforms
+// without native notifications. Defaults to 1s.
+func WithPollInterval(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WithPolling forces polling, which also works on network filesystems that do not
+// deliver native notifications.
+func WithPolling() WatchOption {
+	return func(o *watchOptions) {
+		o.polling = true
+	}
+}
+
+// watchBackend notifies the Watcher of changes in the watched directories.
+type watchBackend interface {
+	add(dir string) error
+	remove(dir string)
+	close() error
+}
+
+// fileState is the snapshot of a path, compared between scans to detect changes.
+type fileState struct {
+	exists  bool
+	isDir   bool
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+	// target is the resolved path of a symbolic link.
+	target string
+}
+
+// watchedFile is a file added to the Watcher, tracked through the directory containing it.
+type watchedFile struct {
+	state fileState
+	// targetDir is the directory of the symbolic link target, also watched.
+	targetDir string
+}
+
+// Watcher reports changes of files and directories.
+//
+// Files are tracked through their directory, so they may be created after being added,
+// and replacing them through a rename or a symbolic link swap (as Kubernetes does for
+// mounted ConfigMaps and Secrets) is reported as a write. Linux uses inotify; other
+// platforms poll.
+type Watcher struct {
+	opts    watchOptions
+	backend watchBackend
+	events  chan WatchEvent
+	errors  chan error
+	notify  chan string
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	mu     sync.Mutex
+	closed bool
+	// refs counts the reasons each directory is watched by the backend.
+	refs map[string]int
+	// dirs maps the watched directories to whether their subdirectories are watched.
+	dirs map[string]bool
+	// roots are the directories added by the caller.
+	roots map[string]bool
+	// entries holds the state of the children of each watched directory.
+	entries map[string]map[string]fileState
+	// files and fileDirs track the individually watched files by path and by directory.
+	files    map[string]*watchedFile
+	fileDirs map[string]map[string]bool
+}
+
+// NewWatcher starts a watcher that runs until ctx is done or Close is called.
+//
+// Example:
+//
+//	w, err := NewWatcher(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	if err := w.Add("config.yaml"); err != nil {
+//		return err
+//	}
+//	for event := range w.Events() {
+//		log.Println(event)
+//	}
+func NewWatcher(ctx context.Context, opts ...WatchOption) (*Watcher, error) {
+	o := watchOptions{debounce: 100 * time.Millisecond, pollInterval: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.pollInterval <= 0 {
+		return nil, errors.New("poll interval must be positive")
+	}
+	w := &Watcher{
+		opts:     o,
+		events:   make(chan WatchEvent, 16),
+		errors:   make(chan error, 4),
+		notify:   make(chan string, 64),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		refs:     make(map[string]int),
+		dirs:     make(map[string]bool),
+		roots:    make(map[string]bool),
+		entries:  make(map[string]map[string]fileState),
+		files:    make(map[string]*watchedFile),
+		fileDirs: make(map[string]map[string]bool),
+	}
+	var err error
+	if !o.polling {
+		w.backend, err = newNativeBackend(w.signal, w.report)
+	}
+	if o.polling || errors.Is(err, errors.ErrUnsupported) {
+		w.backend, err = newPollingBackend(o.pollInterval, w.signal), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start watcher: %w", err)
+	}
+	go w.run(ctx)
+	return w, nil
+}
+
+// Events returns the channel of changes. It is closed when the watcher stops.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Errors returns the channel of errors encountered while watching. It is closed when the watcher stops.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Add watches a file or a directory. A file does not need to exist, but its directory does.
+// Events report paths joined from the added path.
+func (w *Watcher) Add(name string) error {
+	name = filepath.Clean(name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("watcher is closed")
+	}
+
+	info, err := os.Stat(name)
+	if err == nil && info.IsDir() {
+		if w.roots[name] {
+			return nil
+		}
+		if err := w.watchDir(name, w.opts.recursive, nil); err != nil {
+			return err
+		}
+		w.roots[name] = true
+		return nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to watch %s: %w", name, err)
+	}
+	if _, ok := w.files[name]; ok {
+		return nil
+	}
+	parent := filepath.Dir(name)
+	if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+		return fmt.Errorf("failed to watch %s: parent directory does not exist", name)
+	}
+	if err := w.addFileDir(parent, name); err != nil {
+		return err
+	}
+	file := &watchedFile{state: statPath(name)}
+	w.files[name] = file
+	w.followTarget(name, file)
+	return nil
+}
+
+// Close stops the watcher and closes the event and error channels.
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	<-w.stopped
+	return nil
+}
+
+// signal marks a directory as changed; an empty dir marks all of them.
+func (w *Watcher) signal(dir string) {
+	select {
+	case w.notify <- dir:
+	case <-w.done:
+	}
+}
+
+// report delivers an error without blocking the watcher. It is called from the backend,
+// or with the lock held.
+func (w *Watcher) report(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	default:
+	}
+}
+
+// run collects the changed directories and delivers the events once the debounce delay expires.
+func (w *Watcher) run(ctx context.Context) {
+	defer w.shutdown()
+
+	dirty := make(map[string]bool)
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			w.once.Do(func() {
+				close(w.done)
+			})
+			return
+		case <-w.done:
+			return
+		case dir := <-w.notify:
+			dirty[dir] = true
+			if timer == nil && w.opts.debounce > 0 {
+				timer = time.After(w.opts.debounce)
+			}
+			if w.opts.debounce > 0 {
+				continue
+			}
+		case <-timer:
+		}
+		timer = nil
+		events := w.scan(dirty)
+		dirty = make(map[string]bool)
+		for _, event := range events {
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				w.once.Do(func() {
+					close(w.done)
+				})
+				return
+			}
+		}
+	}
+}
+
+// shutdown stops the backend and closes the channels once no more events can be produced.
+func (w *Watcher) shutdown() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	_ = w.backend.close()
+	close(w.events)
+	close(w.errors)
+	close(w.stopped)
+}
+
+// scan rescans the changed directories and returns the resulting events.
+func (w *Watcher) scan(dirty map[string]bool) []WatchEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if dirty[""] {
+		for dir := range w.refs {
+			dirty[dir] = true
+		}
+	}
+	dirs := make([]string, 0, len(dirty))
+	for dir := range dirty {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	// Parents are scanned before their children.
+	sort.Strings(dirs)
+
+	var events []WatchEvent
+	seen := make(map[WatchEvent]bool)
+	emit := func(event WatchEvent) {
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	for _, dir := range dirs {
+		if _, ok := w.dirs[dir]; ok {
+			w.rescanDir(dir, emit)
+		}
+		for name := range w.fileDirs[dir] {
+			file := w.files[name]
+			state := statPath(name)
+			if op, changed := compareStates(file.state, state); changed {
+				emit(WatchEvent{Path: name, Op: op})
+			}
+			file.state = state
+			w.followTarget(name, file)
+		}
+	}
+	return events
+}
+
+// watchDir starts watching dir and records the state of its children, emitting
+// creation events if emit is not nil.
+func (w *Watcher) watchDir(dir string, recursive bool, emit func(WatchEvent)) error {
+	if err := w.addRef(dir); err != nil {
+		return err
+	}
+	w.dirs[dir] = recursive
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.report(fmt.Errorf("failed to read directory %s: %w", dir, err))
+		return nil
+	}
+	states := make(map[string]fileState, len(entries))
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		states[entry.Name()] = statPath(p)
+		if emit != nil {
+			emit(WatchEvent{Path: p, Op: WatchCreate})
+		}
+		if recursive && entry.IsDir() {
+			if err := w.watchDir(p, true, emit); err != nil {
+				w.report(err)
+			}
+		}
+	}
+	w.entries[dir] = states
+	return nil
+}
+
+// rescanDir compares the children of dir with their previous state.
+func (w *Watcher) rescanDir(dir string, emit func(WatchEvent)) {
+	recursive := w.dirs[dir]
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.report(fmt.Errorf("failed to read directory %s: %w", dir, err))
+			return
+		}
+		w.dropDir(dir, emit)
+		if w.roots[dir] {
+			delete(w.roots, dir)
+			emit(WatchEvent{Path: dir, Op: WatchRemove})
+		}
+		return
+	}
+
+	previous := w.entries[dir]
+	current := make(map[string]fileState, len(entries))
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		state := statPath(p)
+		current[entry.Name()] = state
+		if old, ok := previous[entry.Name()]; !ok {
+			emit(WatchEvent{Path: p, Op: WatchCreate})
+		} else if op, changed := compareStates(old, state); changed {
+			emit(WatchEvent{Path: p, Op: op})
+		}
+		_, watched := w.dirs[p]
+		switch {
+		case recursive && entry.IsDir() && !watched:
+			if err := w.watchDir(p, true, emit); err != nil {
+				w.report(err)
+			}
+		case watched && !entry.IsDir():
+			w.dropDir(p, emit)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		emit(WatchEvent{Path: p, Op: WatchRemove})
+		if _, watched := w.dirs[p]; watched {
+			w.dropDir(p, emit)
+		}
+	}
+	w.entries[dir] = current
+}
+
+// dropDir stops watching dir and its subdirectories, emitting removal events for their children.
+func (w *Watcher) dropDir(dir string, emit func(WatchEvent)) {
+	for name := range w.entries[dir] {
+		p := filepath.Join(dir, name)
+		emit(WatchEvent{Path: p, Op: WatchRemove})
+		if _, watched := w.dirs[p]; watched {
+			w.dropDir(p, emit)
+		}
+	}
+	delete(w.entries, dir)
+	delete(w.dirs, dir)
+	w.removeRef(dir)
+}
+
+// followTarget also watches the directory of the target when the file is a symbolic link,
+// so changes of the target are detected.
+func (w *Watcher) followTarget(name string, file *watchedFile) {
+	targetDir := ""
+	if file.state.target != "" {
+		targetDir = filepath.Dir(file.state.target)
+	}
+	if targetDir == file.targetDir {
+		return
+	}
+	if file.targetDir != "" {
+		w.removeFileDir(file.targetDir, name)
+	}
+	file.targetDir = ""
+	if targetDir != "" {
+		if err := w.addFileDir(targetDir, name); err != nil {
+			w.report(err)
+			return
+		}
+		file.targetDir = targetDir
+	}
+}
+
+// addFileDir watches dir on behalf of the file name.
+func (w *Watcher) addFileDir(dir, name string) error {
+	if err := w.addRef(dir); err != nil {
+		return err
+	}
+	if w.fileDirs[dir] == nil {
+		w.fileDirs[dir] = make(map[string]bool)
+	}
+	w.fileDirs[dir][name] = true
+	return nil
+}
+
+// removeFileDir stops watching dir on behalf of the file name.
+func (w *Watcher) removeFileDir(dir, name string) {
+	delete(w.fileDirs[dir], name)
+	if len(w.fileDirs[dir]) == 0 {
+		delete(w.fileDirs, dir)
+	}
+	w.removeRef(dir)
+}
+
+// addRef registers a reason to watch dir, starting the backend watch for the first one.
+func (w *Watcher) addRef(dir string) error {
+	if w.refs[dir] == 0 {
+		if err := w.backend.add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	w.refs[dir]++
+	return nil
+}
+
+// removeRef releases a reason to watch dir, stopping the backend watch after the last one.
+func (w *Watcher) removeRef(dir string) {
+	if w.refs[dir] == 0 {
+		return
+	}
+	w.refs[dir]--
+	if w.refs[dir] == 0 {
+		delete(w.refs, dir)
+		w.backend.remove(dir)
+	}
+}
+
+// statPath returns the state of p, following symbolic links.
+func statPath(p string) fileState {
+	info, err := os.Stat(p)
+	if err != nil {
+		return fileState{}
+	}
+	state := fileState{exists: true, isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime(), mode: info.Mode()}
+	if link, err := os.Lstat(p); err == nil && link.Mode()&fs.ModeSymlink != 0 {
+		state.target, _ = filepath.EvalSymlinks(p)
+	}
+	return state
+}
+
+// compareStates returns the operation turning old into current, if any.
+// The size and modification time of directories are ignored.
+func compareStates(old, current fileState) (WatchOp, bool) {
+	switch {
+	case !old.exists && !current.exists:
+		return 0, false
+	case !old.exists:
+		return WatchCreate, true
+	case !current.exists:
+		return WatchRemove, true
+	case old.target != current.target || old.isDir != current.isDir:
+		return WatchWrite, true
+	case !current.isDir && (old.size != current.size || !old.modTime.Equal(current.modTime)):
+		return WatchWrite, true
+	case old.mode != current.mode:
+		return WatchChmod, true
+	}
+	return 0, false
+}
+
+// pollingBackend signals a full rescan at a fixed interval.
+type pollingBackend struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newPollingBackend starts signalling every interval.
+func newPollingBackend(interval time.Duration, signal func(string)) *pollingBackend {
+	b := &pollingBackend{stop: make(chan struct{})}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				signal("")
+			}
+		}
+	}()
+	return b
+}
+
+func (b *pollingBackend) add(string) error { return nil }
+
+func (b *pollingBackend) remove(string) {}
+
+func (b *pollingBackend) close() error {
+	close(b.stop)
+	b.wg.Wait()
+	return nil
+}
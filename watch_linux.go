@@ -0,0 +1,118 @@
+//go:build linux
+
+package goutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// inotifyMask selects the inotify events of a watched directory.
+const inotifyMask = syscall.IN_ATTRIB | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_DELETE_SELF |
+	syscall.IN_MODIFY | syscall.IN_MOVE_SELF | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyBackend signals the directories reported by inotify.
+type inotifyBackend struct {
+	file   *os.File
+	fd     int
+	signal func(string)
+	report func(error)
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	dirs map[int]string
+	wds  map[string]int
+}
+
+// newNativeBackend starts an inotify instance.
+func newNativeBackend(signal func(string), report func(error)) (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	b := &inotifyBackend{
+		// A non-blocking descriptor is handled by the runtime poller, so Close interrupts Read.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		signal: signal,
+		report: report,
+		dirs:   make(map[int]string),
+		wds:    make(map[string]int),
+	}
+	b.wg.Add(1)
+	go b.read()
+	return b, nil
+}
+
+func (b *inotifyBackend) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(b.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dirs[wd] = dir
+	b.wds[dir] = wd
+	return nil
+}
+
+func (b *inotifyBackend) remove(dir string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wd, ok := b.wds[dir]
+	if !ok {
+		return
+	}
+	delete(b.wds, dir)
+	delete(b.dirs, wd)
+	_, _ = syscall.InotifyRmWatch(b.fd, uint32(wd))
+}
+
+func (b *inotifyBackend) close() error {
+	err := b.file.Close()
+	b.wg.Wait()
+	return err
+}
+
+// read signals the directory of each event until the descriptor is closed.
+func (b *inotifyBackend) read() {
+	defer b.wg.Done()
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				b.report(fmt.Errorf("failed to read inotify events: %w", err))
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			offset += syscall.SizeofInotifyEvent + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				b.signal("")
+				continue
+			}
+			b.mu.Lock()
+			dir, ok := b.dirs[wd]
+			if ok && mask&syscall.IN_IGNORED != 0 {
+				// The watch was removed by the kernel, usually because the directory was deleted.
+				delete(b.dirs, wd)
+				if b.wds[dir] == wd {
+					delete(b.wds, dir)
+				}
+			}
+			b.mu.Unlock()
+			if ok {
+				b.signal(dir)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package goutils
+
+import "errors"
+
+// newNativeBackend reports that native notifications are not implemented, so polling is used.
+func newNativeBackend(func(string), func(error)) (watchBackend, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,172 @@
+package goutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchModes runs a test with the native backend and with polling.
+var watchModes = map[string][]WatchOption{
+	"native":  {WithDebounce(20 * time.Millisecond)},
+	"polling": {WithDebounce(20 * time.Millisecond), WithPolling(), WithPollInterval(20 * time.Millisecond)},
+}
+
+// expectEvent waits for the event, failing the test if it is not received in time.
+func expectEvent(t *testing.T, w *Watcher, want WatchEvent) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("Events closed while waiting for %v", want)
+			}
+			if event == want {
+				return
+			}
+			t.Logf("Skipping event %v", event)
+		case err := <-w.Errors():
+			t.Fatalf("Watcher error: %v", err)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %v", want)
+		}
+	}
+}
+
+func TestWatcherFile(t *testing.T) {
+	for mode, opts := range watchModes {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, "config.json")
+			w, err := NewWatcher(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("Error creating watcher: %v", err)
+			}
+			defer w.Close()
+			if err := w.Add(filePath); err != nil {
+				t.Fatalf("Error watching file: %v", err)
+			}
+
+			writeTestFile(t, dir, "config.json", []byte("{}"))
+			expectEvent(t, w, WatchEvent{Path: filePath, Op: WatchCreate})
+
+			// An atomic replacement is a write, not a removal.
+			if err := WriteFileAtomic(filePath, []byte(`{"debug": true}`)); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, WatchEvent{Path: filePath, Op: WatchWrite})
+
+			if err := os.Remove(filePath); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, WatchEvent{Path: filePath, Op: WatchRemove})
+		})
+	}
+}
+
+func TestWatcherRecursive(t *testing.T) {
+	for mode, opts := range watchModes {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewWatcher(context.Background(), append(opts, WithRecursive())...)
+			if err != nil {
+				t.Fatalf("Error creating watcher: %v", err)
+			}
+			defer w.Close()
+			if err := w.Add(dir); err != nil {
+				t.Fatalf("Error watching directory: %v", err)
+			}
+
+			sub := filepath.Join(dir, "sub")
+			if err := os.Mkdir(sub, 0755); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, WatchEvent{Path: sub, Op: WatchCreate})
+			writeTestFile(t, sub, "a.txt", []byte("a"))
+			expectEvent(t, w, WatchEvent{Path: filepath.Join(sub, "a.txt"), Op: WatchCreate})
+
+			if err := os.RemoveAll(sub); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w, WatchEvent{Path: sub, Op: WatchRemove})
+		})
+	}
+}
+
+func TestWatcherSymlinkSwap(t *testing.T) {
+	// Reproduce the layout of a Kubernetes ConfigMap volume.
+	dir := t.TempDir()
+	createTestTree(t, dir, map[string]string{"..v1/app.yaml": "v1"})
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+	filePath := filepath.Join(dir, "app.yaml")
+	if err := os.Symlink(filepath.Join("..data", "app.yaml"), filePath); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(context.Background(), WithDebounce(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error creating watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.Add(filePath); err != nil {
+		t.Fatalf("Error watching file: %v", err)
+	}
+
+	createTestTree(t, dir, map[string]string{"..v2/app.yaml": "v2"})
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "..v1")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, WatchEvent{Path: filePath, Op: WatchWrite})
+}
+
+func TestWatcherDebounceAndCancel(t *testing.T) {
+	dir := t.TempDir()
+	filePath := writeTestFile(t, dir, "app.log", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := NewWatcher(ctx, WithDebounce(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error creating watcher: %v", err)
+	}
+	if err := w.Add(filePath); err != nil {
+		t.Fatalf("Error watching file: %v", err)
+	}
+
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		_, _ = f.WriteString("line\n")
+	}
+	_ = f.Close()
+	expectEvent(t, w, WatchEvent{Path: filePath, Op: WatchWrite})
+	select {
+	case event := <-w.Events():
+		t.Errorf("Expected a single event for the burst, got %v", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case _, ok := <-w.Events():
+		if ok {
+			t.Errorf("Expected no event after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the events channel to be closed")
+	}
+	if err := w.Add(dir); err == nil {
+		t.Errorf("Expected an error adding a path to a stopped watcher")
+	}
+}